@@ -9,8 +9,10 @@ require (
 	github.com/openshift/library-go v0.0.0-20210318152630-323ad8a8f7d8
 	github.com/spf13/cobra v1.1.3
 	github.com/spf13/pflag v1.0.5
+	k8s.io/api v0.20.5
 	k8s.io/apimachinery v0.20.5
 	k8s.io/client-go v0.20.5
 	k8s.io/component-base v0.20.5
 	k8s.io/klog/v2 v2.8.0
+	sigs.k8s.io/yaml v1.2.0
 )
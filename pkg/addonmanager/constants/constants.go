@@ -0,0 +1,217 @@
+package constants
+
+import "fmt"
+
+const (
+	// AddonLabel is the label on the resources created by the addon manager to indicate
+	// which addon they belong to
+	AddonLabel = "open-cluster-management.io/addon-name"
+
+	// AddonWorkLabel is the label on the manifestworks created by the addon manager to
+	// deploy the addon agent
+	AddonWorkLabel = "open-cluster-management.io/addon-work"
+
+	// AddonWorkTypeLabel is the label on the manifestworks created by the addon manager
+	// naming which controller owns the work, so each controller only ever cleans up the
+	// works it created itself
+	AddonWorkTypeLabel = "open-cluster-management.io/addon-work-type"
+
+	// DeployWorkType is the work type label value of the deploy works
+	DeployWorkType = "deploy"
+
+	// RegistrationWorkType is the work type label value of the registration works
+	RegistrationWorkType = "registration"
+
+	// AddonDeployWaveAnnotation is the annotation on an agent manifest to assign it to a
+	// deploy wave explicitly when the ordered deploy is enabled. The value is an integer;
+	// manifests in a lower wave are applied before those in a higher one.
+	AddonDeployWaveAnnotation = "open-cluster-management.io/addon-deploy-wave"
+
+	// RegistrationConfigAddonNameKey is the data key of the addon name in the registration
+	// config map
+	RegistrationConfigAddonNameKey = "addonName"
+	// RegistrationConfigSignerNameKey is the data key of the signer name in the registration
+	// config map
+	RegistrationConfigSignerNameKey = "signerName"
+	// RegistrationConfigBootstrapSecretKey is the data key of the bootstrap secret name in
+	// the registration config map
+	RegistrationConfigBootstrapSecretKey = "bootstrapSecret"
+	// RegistrationConfigHubKubeconfigSecretKey is the data key of the hub kubeconfig secret
+	// name in the registration config map
+	RegistrationConfigHubKubeconfigSecretKey = "hubKubeconfigSecret"
+	// RegistrationConfigExpirationSecondsKey is the data key of the requested certificate
+	// lifetime in the registration config map
+	RegistrationConfigExpirationSecondsKey = "expirationSeconds"
+	// RegistrationConfigSANsKey is the data key of the comma-separated DNS subject
+	// alternative names in the registration config map
+	RegistrationConfigSANsKey = "sans"
+
+	// AddonManifestDigestAnnotation is the annotation on a deploy manifestwork carrying the
+	// digest of the agent manifests it ships, so the previously deployed version can be told
+	// apart from a fresh install on the next reconcile
+	AddonManifestDigestAnnotation = "open-cluster-management.io/addon-manifest-digest"
+
+	// AddonPausedAnnotation is the annotation on a ManagedClusterAddOn pausing its
+	// reconciliation when set to "true". The hub controllers keep the deployed works
+	// untouched until the annotation is removed, as an operational escape hatch during an
+	// incident or a manual intervention.
+	AddonPausedAnnotation = "addon.open-cluster-management.io/paused"
+
+	// RegistrationSignerAnnotation is the annotation on a ManagedClusterAddOn carrying the
+	// resolved signer name of its agent registration.
+	// TODO: surface these registration details in a typed status field once the vendored
+	// addon API grows one; the annotations then stay for a transition period only.
+	RegistrationSignerAnnotation = "addon.open-cluster-management.io/signer"
+	// RegistrationInstallNamespaceAnnotation is the annotation on a ManagedClusterAddOn
+	// carrying the resolved install namespace of its agent registration
+	RegistrationInstallNamespaceAnnotation = "addon.open-cluster-management.io/install-namespace"
+	// RegistrationBootstrapSecretAnnotation is the annotation on a ManagedClusterAddOn
+	// carrying the resolved bootstrap secret name of its agent registration
+	RegistrationBootstrapSecretAnnotation = "addon.open-cluster-management.io/bootstrap-secret"
+	// RegistrationHubKubeconfigSecretAnnotation is the annotation on a ManagedClusterAddOn
+	// carrying the resolved hub kubeconfig secret name of its agent registration
+	RegistrationHubKubeconfigSecretAnnotation = "addon.open-cluster-management.io/hub-kubeconfig-secret"
+
+	// RegistrationBootstrappedCondition is the condition type set on the ManagedClusterAddOn
+	// by the certificate manager of the spoke agent once a valid hub client config exists, so
+	// the hub can observe that the bootstrap credential is no longer needed
+	RegistrationBootstrappedCondition = "RegistrationBootstrapped"
+
+	// RegistrationAppliedCondition is the condition type set on the ManagedClusterAddOn by the
+	// registration controller once the registration manifestwork is applied, so a registration
+	// stuck before the bootstrap even reaches the managed cluster is distinguishable from one
+	// stuck on the cluster
+	RegistrationAppliedCondition = "RegistrationApplied"
+
+	// ClusterCertificateRotatedCondition is the condition type set on the ManagedClusterAddOn
+	// by the certificate manager of the spoke agent whenever a client certificate is in place,
+	// carrying the agent identity and the certificate expiry in the message
+	ClusterCertificateRotatedCondition = "ClusterCertificateRotated"
+
+	// OwnerAddonAnnotation is the provenance annotation stamped on every deployed resource
+	// with the name of the addon that shipped it, so inspecting a resource on the managed
+	// cluster reveals where it came from
+	OwnerAddonAnnotation = "addon.open-cluster-management.io/owner-addon"
+	// SourceClusterAnnotation is the provenance annotation stamped on every deployed
+	// resource with the cluster namespace on the hub it was deployed for
+	SourceClusterAnnotation = "addon.open-cluster-management.io/source-cluster"
+
+	// AddonRenderInputsAnnotation is the annotation on a deploy manifestwork carrying the
+	// fingerprint of the render inputs the manifests were rendered from, so an unchanged
+	// fingerprint can skip a re-render
+	AddonRenderInputsAnnotation = "open-cluster-management.io/addon-render-inputs"
+
+	// AddonDeployWorksAnnotation is the annotation on a deploy manifestwork naming every
+	// deploy manifestwork of the same apply, comma separated, so a sibling work deleted
+	// out-of-band, e.g. a single wave work of an ordered deploy, is noticed and recreated
+	// even when the render input fingerprint is unchanged
+	AddonDeployWorksAnnotation = "open-cluster-management.io/addon-deploy-works"
+
+	// AddonDeployFinalizer is the finalizer on the ManagedClusterAddOn making sure the deploy
+	// manifestworks of its agent are removed before the addon itself goes away
+	AddonDeployFinalizer = "open-cluster-management.io/addon-agent-cleanup"
+)
+
+// WorkExecutorAnnotation is the annotation on the manifestworks of the addon naming the
+// "<namespace>/<name>" of the service account the work agent should apply the manifests
+// as, so a multi-tenant spoke can constrain the addon to least privilege.
+// TODO: set spec.Executor instead once the vendored work API carries the field.
+const WorkExecutorAnnotation = "open-cluster-management.io/work-executor"
+
+// ManifestIDAnnotation is the annotation on an agent manifest carrying the stable identity
+// the addon author declared for it through AgentManifestsWithIDs. It is stamped by the deploy
+// controller and travels with the object to the managed cluster.
+const ManifestIDAnnotation = "addon.open-cluster-management.io/manifest-id"
+
+// AddonManifestIDsAnnotation is the annotation on a deploy manifestwork recording the declared
+// manifest identities of the last render as a JSON map from the manifest ID to the coordinates
+// of its object. The deploy controller diffs the record on every re-render to tell renamed
+// manifests from removed ones.
+const AddonManifestIDsAnnotation = "open-cluster-management.io/addon-manifest-ids"
+
+// SupportedConfigsAnnotation is the annotation on a ClusterManagementAddOn advertising the
+// configuration resources the manager of the addon understands, as a comma-separated list of
+// "<group>/<version>/<resource>" coordinates, so consumers and UIs know which config kinds
+// are valid for the addon.
+// TODO: populate the typed supportedConfigs field instead once the vendored addon API grows
+// one; the annotation then stays for a transition period only.
+const SupportedConfigsAnnotation = "addon.open-cluster-management.io/supported-configs"
+
+// HubCABundleKey is the key of the CA bundle in the hub CA config map injected into the
+// deploy work by WithHubCAConfigMap
+const HubCABundleKey = "ca-bundle.crt"
+
+// LastAppliedConfigHashAnnotation is the annotation on a ManagedClusterAddOn carrying the
+// hash of the addon configuration the last successfully applied deploy work was rendered
+// from, so tooling can diff the desired and the applied config without decoding the work
+const LastAppliedConfigHashAnnotation = "addon.open-cluster-management.io/last-applied-config-hash"
+
+// AddonLeaseNamespaceAnnotation is the annotation on a ManagedClusterAddOn naming the
+// namespace the agent keeps its lease in, for agents that run outside of the install
+// namespace of their workloads. Without it the lease is looked up in any namespace.
+const AddonLeaseNamespaceAnnotation = "addon.open-cluster-management.io/lease-namespace"
+
+// AddonLeaseNameAnnotation is the annotation on a ManagedClusterAddOn naming the lease of
+// the agent, defaulting to the addon name
+const AddonLeaseNameAnnotation = "addon.open-cluster-management.io/lease-name"
+
+// AddonRecreateOnChangeAnnotation is the annotation an addon author sets on an agent manifest
+// to flag that a change to it is breaking and the resource must be recreated rather than
+// updated in place. It is honored when the manager runs with the Recreate upgrade strategy.
+const AddonRecreateOnChangeAnnotation = "addon.open-cluster-management.io/recreate-on-change"
+
+// AddonRecreateDigestAnnotation is the annotation on a deploy manifestwork recording the
+// digest of the manifest version whose recreate deletion phase has been shipped, so the
+// follow-up reconcile knows to apply the full version
+const AddonRecreateDigestAnnotation = "open-cluster-management.io/addon-recreate-digest"
+
+// AddonCriticalManifestAnnotation is the annotation an addon author sets on an agent manifest
+// to flag it as critical under the BestEffort failure policy: its failure degrades the addon
+// while failures of unflagged manifests are tolerated
+const AddonCriticalManifestAnnotation = "addon.open-cluster-management.io/critical"
+
+// RegistrationWorkExpiryAnnotation is the annotation on a registration manifestwork carrying
+// the RFC3339 time past which the work is deleted by the time-based expiry configured with
+// WithManifestWorkTTL, so an operator inspecting the work sees when it goes away
+const RegistrationWorkExpiryAnnotation = "open-cluster-management.io/registration-work-expiry"
+
+// ProxyConfigURLKey is the data key of the proxy endpoint in the config map describing a
+// cluster-proxy deployment on the hub
+const ProxyConfigURLKey = "proxyURL"
+
+// ProxyConfigCAKey is the data key of the CA bundle of the proxy endpoint in the config map
+// describing a cluster-proxy deployment on the hub
+const ProxyConfigCAKey = "ca.crt"
+
+// DeployWorkName returns the name of the manifestwork deploying the agent of the addon
+func DeployWorkName(addonName string) string {
+	return fmt.Sprintf("addon-%s-deploy", addonName)
+}
+
+// RegistrationWorkName returns the name of the manifestwork deploying the registration
+// bootstrap of the agent of the addon
+func RegistrationWorkName(addonName string) string {
+	return fmt.Sprintf("addon-%s-registration", addonName)
+}
+
+// RegistrationConfigMapName returns the name of the config map carrying the registration
+// config of the addon in its install namespace on the managed cluster
+func RegistrationConfigMapName(addonName string) string {
+	return fmt.Sprintf("%s-registration-config", addonName)
+}
+
+// SignerRegistrationConfigMapName returns the name of the config map carrying the
+// registration config of the addon for one of its signers. The first signer keeps the
+// unsuffixed name so agents of addons with a single signer are unaffected.
+func SignerRegistrationConfigMapName(addonName string, index int) string {
+	if index == 0 {
+		return RegistrationConfigMapName(addonName)
+	}
+	return fmt.Sprintf("%s-%d", RegistrationConfigMapName(addonName), index)
+}
+
+// DeployWaveWorkName returns the name of the manifestwork deploying one wave of the agent
+// manifests when the ordered deploy is enabled
+func DeployWaveWorkName(addonName string, wave int) string {
+	return fmt.Sprintf("%s-wave-%d", DeployWorkName(addonName), wave)
+}
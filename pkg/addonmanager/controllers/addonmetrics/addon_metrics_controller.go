@@ -0,0 +1,94 @@
+package addonmetrics
+
+import (
+	"context"
+
+	addoninformerv1alpha1 "github.com/open-cluster-management/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "github.com/open-cluster-management/api/client/addon/listers/addon/v1alpha1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+	"k8s.io/klog/v2"
+)
+
+// availableConditionType is the condition type on the ManagedClusterAddOn reporting whether
+// the addon agent is available on the managed cluster
+const availableConditionType = "Available"
+
+// addonAvailable reports the availability of the addons across the fleet derived from the
+// Available condition of the ManagedClusterAddOns: 1 when available, 0 otherwise. The series
+// of a deleted addon is removed to keep the cardinality bounded.
+var addonAvailable = metrics.NewGaugeVec(&metrics.GaugeOpts{
+	Name: "addon_available",
+	Help: "Whether the addon agent on the managed cluster is available.",
+}, []string{"addon", "cluster"})
+
+func init() {
+	legacyregistry.MustRegister(addonAvailable)
+}
+
+// addonMetricsController maintains the fleet-wide addon metrics on the metrics endpoint of the
+// hub manager.
+type addonMetricsController struct {
+	addonName   string
+	addonLister addonlisterv1alpha1.ManagedClusterAddOnLister
+}
+
+// NewAddonMetricsController returns a controller updating the addon metrics as the addon
+// conditions change
+func NewAddonMetricsController(
+	addonName string,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	recorder events.Recorder,
+) factory.Controller {
+	c := &addonMetricsController{
+		addonName:   addonName,
+		addonLister: addonInformers.Lister(),
+	}
+
+	return factory.New().
+		WithInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				accessor, _ := apimeta.Accessor(obj)
+				return accessor.GetNamespace() + "/" + accessor.GetName()
+			},
+			addonInformers.Informer()).
+		WithSync(InstrumentSync("AddonMetricsController", c.sync)).
+		ToController("AddonMetricsController", recorder)
+}
+
+func (c *addonMetricsController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	key := syncCtx.QueueKey()
+	klog.V(4).Infof("Reconciling addon metrics %q", key)
+
+	clusterName, addonName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore addon whose key is not in format: namespace/name
+		return nil
+	}
+	if addonName != c.addonName {
+		return nil
+	}
+
+	addon, err := c.addonLister.ManagedClusterAddOns(clusterName).Get(addonName)
+	switch {
+	case errors.IsNotFound(err):
+		// the addon or its cluster is deleted, drop its series
+		addonAvailable.DeleteLabelValues(addonName, clusterName)
+		return nil
+	case err != nil:
+		return err
+	}
+
+	available := 0.0
+	if apimeta.IsStatusConditionTrue(addon.Status.Conditions, availableConditionType) {
+		available = 1.0
+	}
+	addonAvailable.WithLabelValues(addonName, clusterName).Set(available)
+	return nil
+}
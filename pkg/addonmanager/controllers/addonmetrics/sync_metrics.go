@@ -0,0 +1,43 @@
+package addonmetrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"k8s.io/component-base/metrics"
+	"k8s.io/component-base/metrics/legacyregistry"
+)
+
+// syncDuration reports how long the syncs of each controller take, so a slow controller can
+// be told apart from an erroring one at fleet scale
+var syncDuration = metrics.NewHistogramVec(&metrics.HistogramOpts{
+	Name:    "addon_controller_sync_duration_seconds",
+	Help:    "Duration of the controller syncs.",
+	Buckets: metrics.DefBuckets,
+}, []string{"controller"})
+
+// syncErrors counts the failed syncs of each controller
+var syncErrors = metrics.NewCounterVec(&metrics.CounterOpts{
+	Name: "addon_controller_sync_errors_total",
+	Help: "Total number of failed controller syncs.",
+}, []string{"controller"})
+
+func init() {
+	legacyregistry.MustRegister(syncDuration)
+	legacyregistry.MustRegister(syncErrors)
+}
+
+// InstrumentSync wraps the sync of a controller to record its duration and error count on
+// the metrics endpoint of the manager, labeled with the controller name
+func InstrumentSync(controller string, sync factory.SyncFunc) factory.SyncFunc {
+	return func(ctx context.Context, syncCtx factory.SyncContext) error {
+		start := time.Now()
+		err := sync(ctx, syncCtx)
+		syncDuration.WithLabelValues(controller).Observe(time.Since(start).Seconds())
+		if err != nil {
+			syncErrors.WithLabelValues(controller).Inc()
+		}
+		return err
+	}
+}
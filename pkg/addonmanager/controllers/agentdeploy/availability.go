@@ -0,0 +1,110 @@
+package agentdeploy
+
+import (
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	appsv1 "k8s.io/api/apps/v1"
+	policyv1beta1 "k8s.io/api/policy/v1beta1"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+)
+
+// pdbWorkloadKinds are the workload kinds a PodDisruptionBudget is injected for. DaemonSet and
+// Job pods are excluded since an eviction budget over them is not meaningful.
+var pdbWorkloadKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+}
+
+// withPodDisruptionBudgets appends a PodDisruptionBudget manifest for every Deployment and
+// StatefulSet in the agent manifests when a minAvailable is configured on the manager, so HA
+// agents survive node drains without the author shipping the boilerplate. The budget selects
+// the pods through the selector of the workload and is named after it. A budget the author
+// ships with the same coordinates wins, and workloads without a selector are skipped.
+func (p *ManifestPipeline) withPodDisruptionBudgets(cluster *clusterv1.ManagedCluster, objects []runtime.Object) []runtime.Object {
+	if len(p.minAvailable) == 0 {
+		return objects
+	}
+	installNamespace := p.agentAddon.GetAgentAddonOptions().InstallNamespace(cluster)
+
+	shippedBudgets := map[string]bool{}
+	for _, object := range objects {
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			continue
+		}
+		if unstructuredObject, ok := object.(*unstructured.Unstructured); ok {
+			if unstructuredObject.GetKind() == "PodDisruptionBudget" {
+				shippedBudgets[accessor.GetNamespace()+"/"+accessor.GetName()] = true
+			}
+			continue
+		}
+		if _, ok := object.(*policyv1beta1.PodDisruptionBudget); ok {
+			shippedBudgets[accessor.GetNamespace()+"/"+accessor.GetName()] = true
+		}
+	}
+
+	minAvailable := intstr.Parse(p.minAvailable)
+	var budgets []runtime.Object
+	for _, object := range objects {
+		name, namespace, selector := workloadSelector(object)
+		if selector == nil || (len(selector.MatchLabels) == 0 && len(selector.MatchExpressions) == 0) {
+			continue
+		}
+		if len(namespace) == 0 {
+			namespace = installNamespace
+		}
+		if shippedBudgets[namespace+"/"+name] {
+			continue
+		}
+		budgets = append(budgets, &policyv1beta1.PodDisruptionBudget{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "policy/v1beta1",
+				Kind:       "PodDisruptionBudget",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					constants.AddonLabel: p.addonName,
+				},
+			},
+			Spec: policyv1beta1.PodDisruptionBudgetSpec{
+				MinAvailable: &minAvailable,
+				Selector:     selector,
+			},
+		})
+	}
+	return append(objects, budgets...)
+}
+
+// workloadSelector returns the coordinates and the pod selector of the given object when it is
+// a workload a PodDisruptionBudget is injected for, and a nil selector otherwise
+func workloadSelector(object runtime.Object) (name, namespace string, selector *metav1.LabelSelector) {
+	switch workload := object.(type) {
+	case *unstructured.Unstructured:
+		if !pdbWorkloadKinds[workload.GetKind()] {
+			return "", "", nil
+		}
+		content, found, err := unstructured.NestedMap(workload.Object, "spec", "selector")
+		if err != nil || !found {
+			return "", "", nil
+		}
+		selector = &metav1.LabelSelector{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, selector); err != nil {
+			return "", "", nil
+		}
+		return workload.GetName(), workload.GetNamespace(), selector
+	case *appsv1.Deployment:
+		return workload.Name, workload.Namespace, workload.Spec.Selector
+	case *appsv1.StatefulSet:
+		return workload.Name, workload.Namespace, workload.Spec.Selector
+	}
+	return "", "", nil
+}
@@ -0,0 +1,52 @@
+package agentdeploy
+
+import (
+	"strings"
+
+	"github.com/open-cluster-management/addon-framework/pkg/agent"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// filterByRequiredClaims drops the manifests whose required claim annotation is not satisfied
+// by the claims the managed cluster reports, so per-cluster manifest variation stays
+// declarative in the manifests instead of branching in AgentManifests. Manifests without the
+// annotation are always kept.
+func filterByRequiredClaims(cluster *clusterv1.ManagedCluster, objects []runtime.Object) []runtime.Object {
+	filtered := []runtime.Object{}
+	for _, object := range objects {
+		if claimSatisfied(cluster, object) {
+			filtered = append(filtered, object)
+		}
+	}
+	return filtered
+}
+
+// claimSatisfied returns whether the cluster reports the claim the manifest requires. A
+// requirement without a value only requires the claim to be reported.
+func claimSatisfied(cluster *clusterv1.ManagedCluster, object runtime.Object) bool {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return true
+	}
+	requirement, ok := accessor.GetAnnotations()[agent.RequiredClaimAnnotation]
+	if !ok || len(requirement) == 0 {
+		return true
+	}
+
+	name := requirement
+	value := ""
+	hasValue := false
+	if index := strings.Index(requirement, "="); index >= 0 {
+		name = requirement[:index]
+		value = requirement[index+1:]
+		hasValue = true
+	}
+
+	claimValue, reported := agent.ClusterClaimValue(cluster, name)
+	if !reported {
+		return false
+	}
+	return !hasValue || claimValue == value
+}
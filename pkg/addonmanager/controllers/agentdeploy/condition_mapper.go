@@ -0,0 +1,48 @@
+package agentdeploy
+
+import (
+	"context"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ConditionMapper derives addon conditions from the status feedback of a deploy manifestwork,
+// so authors can express domain-specific health, e.g. "database replicas in sync", without
+// owning a controller. The returned conditions are merged into the addon status whenever the
+// work status changes. Authors should namespace their condition types so they do not collide
+// with the conditions the framework maintains itself.
+type ConditionMapper func(work *workapiv1.ManifestWork) []metav1.Condition
+
+// updateMappedConditions runs the registered condition mappers over the deploy works of the
+// addon and merges the derived conditions into the addon status
+func (c *addonDeployController) updateMappedConditions(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+	if len(c.conditionMappers) == 0 {
+		return nil
+	}
+
+	works, err := c.workLister.ManifestWorks(addon.Namespace).List(
+		labels.SelectorFromSet(labels.Set{constants.AddonWorkLabel: c.addonName, constants.AddonWorkTypeLabel: constants.DeployWorkType}))
+	if err != nil {
+		return err
+	}
+
+	updateFuncs := []helpers.UpdateAddonStatusFunc{}
+	for _, work := range works {
+		for _, mapper := range c.conditionMappers {
+			for _, cond := range mapper(work) {
+				updateFuncs = append(updateFuncs, helpers.UpdateAddonConditionFn(cond))
+			}
+		}
+	}
+	if len(updateFuncs) == 0 {
+		return nil
+	}
+
+	_, _, err = helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, updateFuncs...)
+	return err
+}
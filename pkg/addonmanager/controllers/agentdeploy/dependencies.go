@@ -0,0 +1,64 @@
+package agentdeploy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WaitingForDependency is the condition type reporting that the deploy is deferred until the
+// addons this addon depends on are available on the managed cluster.
+const WaitingForDependency = "WaitingForDependency"
+
+// checkDependencies checks that every addon this addon depends on exists in the same cluster
+// namespace and reports the Available condition, and reports the outcome on the addon. It
+// returns whether the deploy should proceed; a deferred deploy is requeued by the addon
+// informer when a dependency becomes available.
+func (c *addonDeployController) checkDependencies(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn) (bool, error) {
+	if len(c.dependencies) == 0 {
+		return true, nil
+	}
+
+	pending := []string{}
+	for _, dependency := range c.dependencies {
+		dependencyAddon, err := c.addonLister.ManagedClusterAddOns(addon.Namespace).Get(dependency)
+		switch {
+		case errors.IsNotFound(err):
+			pending = append(pending, dependency)
+			continue
+		case err != nil:
+			return false, err
+		}
+		if !meta.IsStatusConditionTrue(dependencyAddon.Status.Conditions, "Available") {
+			pending = append(pending, dependency)
+		}
+	}
+	sort.Strings(pending)
+
+	cond := metav1.Condition{
+		Type:    WaitingForDependency,
+		Status:  metav1.ConditionFalse,
+		Reason:  "DependenciesAvailable",
+		Message: "All addons this addon depends on are available",
+	}
+	if len(pending) > 0 {
+		cond = metav1.Condition{
+			Type:    WaitingForDependency,
+			Status:  metav1.ConditionTrue,
+			Reason:  "DependencyUnavailable",
+			Message: fmt.Sprintf("The deploy is deferred until the following addons are available: %s", strings.Join(pending, ", ")),
+		}
+	}
+
+	if _, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond)); err != nil {
+		return false, err
+	}
+	return len(pending) == 0, nil
+}
@@ -0,0 +1,1465 @@
+package agentdeploy
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/controllers/addonmetrics"
+	"github.com/open-cluster-management/addon-framework/pkg/agent"
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	addonv1alpha1client "github.com/open-cluster-management/api/client/addon/clientset/versioned"
+	addoninformerv1alpha1 "github.com/open-cluster-management/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "github.com/open-cluster-management/api/client/addon/listers/addon/v1alpha1"
+	clusterinformersv1 "github.com/open-cluster-management/api/client/cluster/informers/externalversions/cluster/v1"
+	clusterlisterv1 "github.com/open-cluster-management/api/client/cluster/listers/cluster/v1"
+	workv1client "github.com/open-cluster-management/api/client/work/clientset/versioned"
+	workinformersv1 "github.com/open-cluster-management/api/client/work/informers/externalversions/work/v1"
+	worklisterv1 "github.com/open-cluster-management/api/client/work/listers/work/v1"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog/v2"
+)
+
+// ExtraInformer wires an additional resource watch into the queue of the deploy controller,
+// e.g. a secret in the cluster namespaces or a cluster-scoped infrastructure object the
+// rendering depends on. The queue key function maps an event on the watched resource to the
+// "<cluster namespace>/<addon name>" key of the install to re-render; return
+// factory.DefaultQueueKey to re-render every install. The caller keeps the informer and reads
+// the watched resources through its lister during rendering.
+type ExtraInformer struct {
+	// Informer watches the additional resource. It is started by the manager.
+	Informer cache.SharedIndexInformer
+
+	// QueueKeyFn maps an event on the watched resource to the install to re-render.
+	QueueKeyFn factory.ObjectQueueKeyFunc
+}
+
+// addonDeployController deploys the addon agent on the managed cluster by maintaining a
+// manifestwork in the cluster namespace on the hub.
+type addonDeployController struct {
+	*ManifestPipeline
+
+	addonClient          addonv1alpha1client.Interface
+	workClient           workv1client.Interface
+	dynamicClient        dynamic.Interface
+	addonLister          addonlisterv1alpha1.ManagedClusterAddOnLister
+	clusterLister        clusterlisterv1.ManagedClusterLister
+	workLister           worklisterv1.ManifestWorkLister
+	clusterNamespaces    sets.String
+	clusterFilter        agent.ManagedClusterFilter
+	finalizerName        string
+	dependencies         []string
+	conditionMappers     []ConditionMapper
+	allowedSystemNS      sets.String
+	requireAvailable     bool
+	configMapName        string
+	configSelector       map[string]string
+	configMapLister      corev1listers.ConfigMapLister
+	secretSelector       map[string]string
+	secretLister         corev1listers.SecretLister
+	hubAPIServerURL      string
+	proxyConfigNamespace string
+	proxyConfigName      string
+	configDefaulter      func(config runtime.Object) runtime.Object
+	defaultConfig        runtime.Object
+	orderedDeploy        bool
+	deletePropagation    metav1.DeletionPropagation
+	failurePolicy        agent.FailurePolicy
+	rolloutGate          *rolloutGate
+	upgradeStrategy      agent.UpgradeStrategy
+	maxWorkSize          int
+	workExecutor         string
+	workWriteLimiter     flowcontrol.RateLimiter
+	reconcileLock        *helpers.KeyedLock
+	requeueDelay         time.Duration
+	workApplyTimeout     time.Duration
+	workAgentGracePeriod time.Duration
+	eventRecorder        events.Recorder
+}
+
+// NewAddonDeployController returns a controller syncing the deploy manifestwork of the addon agent
+func NewAddonDeployController(
+	addonName string,
+	agentAddon agent.AgentAddon,
+	addonClient addonv1alpha1client.Interface,
+	workClient workv1client.Interface,
+	dynamicClient dynamic.Interface,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	clusterInformers clusterinformersv1.ManagedClusterInformer,
+	workInformers workinformersv1.ManifestWorkInformer,
+	restMapper meta.RESTMapper,
+	clusterNamespaces []string,
+	clusterFilter agent.ManagedClusterFilter,
+	finalizerName string,
+	dependencies []string,
+	overrides []ManifestOverride,
+	transformers []ManifestTransformer,
+	conditionMappers []ConditionMapper,
+	createNamespace bool,
+	namespaceLabels map[string]string,
+	allowedSystemNamespaces []string,
+	serviceAccountName string,
+	serviceAccountNS string,
+	minAvailable string,
+	pullSecretName string,
+	pullSecretData []byte,
+	hubCABundle []byte,
+	hubCAConfigMapName string,
+	provenance bool,
+	requireAvailable bool,
+	configMapName string,
+	configSelector map[string]string,
+	configMapInformers corev1informers.ConfigMapInformer,
+	secretSelector map[string]string,
+	secretInformers corev1informers.SecretInformer,
+	configInformer informers.GenericInformer,
+	extraInformers []ExtraInformer,
+	hubAPIServerURL string,
+	proxyConfigNamespace string,
+	proxyConfigName string,
+	configDefaulter func(config runtime.Object) runtime.Object,
+	defaultConfig runtime.Object,
+	orderedDeploy bool,
+	upgradeStrategy agent.UpgradeStrategy,
+	deletePropagation metav1.DeletionPropagation,
+	failurePolicy agent.FailurePolicy,
+	maxConcurrentClusters int,
+	maxWorkSize int,
+	workExecutorName string,
+	workExecutorNamespace string,
+	workWriteLimiter flowcontrol.RateLimiter,
+	reconcileLock *helpers.KeyedLock,
+	requeueDelay time.Duration,
+	workApplyTimeout time.Duration,
+	workAgentGracePeriod time.Duration,
+	recorder events.Recorder,
+) factory.Controller {
+	c := &addonDeployController{
+		ManifestPipeline: NewManifestPipeline(
+			addonName,
+			agentAddon,
+			restMapper,
+			overrides,
+			transformers,
+			createNamespace,
+			namespaceLabels,
+			serviceAccountName,
+			serviceAccountNS,
+			minAvailable,
+			pullSecretName,
+			pullSecretData,
+			hubCABundle,
+			hubCAConfigMapName,
+			provenance),
+		addonClient:          addonClient,
+		workClient:           workClient,
+		dynamicClient:        dynamicClient,
+		addonLister:          addonInformers.Lister(),
+		clusterLister:        clusterInformers.Lister(),
+		workLister:           workInformers.Lister(),
+		clusterNamespaces:    sets.NewString(clusterNamespaces...),
+		clusterFilter:        clusterFilter,
+		finalizerName:        finalizerName,
+		dependencies:         dependencies,
+		conditionMappers:     conditionMappers,
+		allowedSystemNS:      sets.NewString(allowedSystemNamespaces...),
+		requireAvailable:     requireAvailable,
+		configMapName:        configMapName,
+		configSelector:       configSelector,
+		configMapLister:      configMapInformers.Lister(),
+		secretSelector:       secretSelector,
+		hubAPIServerURL:      hubAPIServerURL,
+		proxyConfigNamespace: proxyConfigNamespace,
+		proxyConfigName:      proxyConfigName,
+		configDefaulter:      configDefaulter,
+		defaultConfig:        defaultConfig,
+		orderedDeploy:        orderedDeploy,
+		deletePropagation:    deletePropagation,
+		failurePolicy:        failurePolicy,
+		rolloutGate:          newRolloutGate(maxConcurrentClusters),
+		upgradeStrategy:      upgradeStrategy,
+		maxWorkSize:          maxWorkSize,
+		workExecutor:         executorSubject(workExecutorNamespace, workExecutorName),
+		workWriteLimiter:     workWriteLimiter,
+		reconcileLock:        reconcileLock,
+		requeueDelay:         requeueDelay,
+		workApplyTimeout:     workApplyTimeout,
+		workAgentGracePeriod: workAgentGracePeriod,
+		eventRecorder:        recorder.WithComponentSuffix("addon-deploy-controller"),
+	}
+
+	dependencySet := sets.NewString(dependencies...)
+	// an addon rendering with the installed-addons context re-renders when any sibling addon
+	// of the cluster namespace comes or goes
+	_, watchSiblings := agentAddon.(agent.AgentAddonWithInstalledAddons)
+	if !watchSiblings {
+		_, watchSiblings = agentAddon.(agent.AgentAddonWithManifestInputs)
+	}
+	controllerFactory := factory.New().
+		WithInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				accessor, _ := meta.Accessor(obj)
+				if dependencySet.Has(accessor.GetName()) || (watchSiblings && accessor.GetName() != addonName) {
+					// a dependency or a sibling changed; requeue the addon of its cluster
+					return accessor.GetNamespace() + "/" + addonName
+				}
+				return accessor.GetNamespace() + "/" + accessor.GetName()
+			},
+			addonInformers.Informer()).
+		WithInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				accessor, _ := meta.Accessor(obj)
+				return accessor.GetName() + "/" + addonName
+			},
+			clusterInformers.Informer()).
+		// requeue the cluster of a deploy work on any work event, so a work deleted
+		// out-of-band is recreated immediately instead of on the next resync
+		WithFilteredEventsInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				accessor, _ := meta.Accessor(obj)
+				return accessor.GetNamespace() + "/" + addonName
+			},
+			func(obj interface{}) bool {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				accessor, err := meta.Accessor(obj)
+				if err != nil {
+					return false
+				}
+				return accessor.GetLabels()[constants.AddonWorkLabel] == addonName
+			},
+			workInformers.Informer())
+
+	if configInformer != nil {
+		// requeue every install of the addon when the config CR changes; the CR is
+		// cluster-agnostic so any change may affect every cluster
+		controllerFactory = controllerFactory.WithInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				return factory.DefaultQueueKey
+			},
+			configInformer.Informer())
+	}
+
+	for _, extraInformer := range extraInformers {
+		if extraInformer.Informer == nil || extraInformer.QueueKeyFn == nil {
+			continue
+		}
+		controllerFactory = controllerFactory.WithInformersQueueKeyFunc(extraInformer.QueueKeyFn, extraInformer.Informer)
+	}
+
+	if len(secretSelector) > 0 && secretInformers != nil {
+		c.secretLister = secretInformers.Lister()
+		// requeue the cluster of a changed matching secret so its addon is re-rendered; the
+		// secret contents never reach a log line on this path
+		secretLabelSelector := labels.SelectorFromSet(secretSelector)
+		controllerFactory = controllerFactory.WithFilteredEventsInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				accessor, _ := meta.Accessor(obj)
+				return accessor.GetNamespace() + "/" + addonName
+			},
+			func(obj interface{}) bool {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				accessor, err := meta.Accessor(obj)
+				if err != nil {
+					return false
+				}
+				return secretLabelSelector.Matches(labels.Set(accessor.GetLabels()))
+			},
+			secretInformers.Informer())
+	}
+
+	if len(configMapName) != 0 && configMapInformers != nil {
+		// requeue the cluster of a changed config map so its addon is re-rendered
+		controllerFactory = controllerFactory.WithInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				accessor, _ := meta.Accessor(obj)
+				return accessor.GetNamespace() + "/" + addonName
+			},
+			configMapInformers.Informer())
+	}
+
+	return controllerFactory.
+		WithSync(addonmetrics.InstrumentSync("AddonDeployController", func(ctx context.Context, syncCtx factory.SyncContext) error {
+			if c.reconcileLock != nil {
+				// serialize with the other controllers acting on the same cluster
+				if clusterName, _, err := cache.SplitMetaNamespaceKey(syncCtx.QueueKey()); err == nil && len(clusterName) > 0 {
+					c.reconcileLock.Lock(clusterName)
+					defer c.reconcileLock.Unlock(clusterName)
+				}
+			}
+			// back off gently on transient API errors instead of the tight per-item retry
+			return helpers.RequeueOnTransient(syncCtx, c.sync(ctx, syncCtx), c.requeueDelay)
+		})).
+		ToController("AddonDeployController", recorder)
+}
+
+func (c *addonDeployController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	key := syncCtx.QueueKey()
+	klog.V(4).Infof("Reconciling addon deploy %q", key)
+
+	if key == factory.DefaultQueueKey {
+		// the addon config changed; requeue every install of the addon
+		addons, err := c.addonLister.List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		for _, addon := range addons {
+			if addon.Name != c.addonName {
+				continue
+			}
+			syncCtx.Queue().Add(addon.Namespace + "/" + addon.Name)
+		}
+		return nil
+	}
+
+	clusterName, addonName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore addon whose key is not in format: namespace/name
+		return nil
+	}
+	if addonName != c.addonName {
+		return nil
+	}
+	if c.clusterNamespaces.Len() > 0 && !c.clusterNamespaces.Has(clusterName) {
+		// the cluster namespace belongs to another manager shard
+		return nil
+	}
+
+	addon, err := c.addonLister.ManagedClusterAddOns(clusterName).Get(addonName)
+	switch {
+	case errors.IsNotFound(err):
+		// the addon is deleted, remove the deploy works
+		return c.removeWorks(ctx, clusterName)
+	case err != nil:
+		return err
+	}
+
+	if !addon.DeletionTimestamp.IsZero() {
+		return c.uninstall(ctx, addon)
+	}
+
+	cluster, err := c.clusterLister.Get(clusterName)
+	switch {
+	case errors.IsNotFound(err):
+		// the managed cluster is gone but the addon still exists; clean up the works and
+		// drop the finalizer so the addon does not block the deletion of the terminating
+		// cluster namespace
+		if err := c.removeWorks(ctx, clusterName); err != nil {
+			return err
+		}
+		return c.removeFinalizer(ctx, addon)
+	case err != nil:
+		return err
+	}
+
+	if !cluster.DeletionTimestamp.IsZero() {
+		return c.removeWorks(ctx, clusterName)
+	}
+
+	if c.clusterFilter != nil && !c.clusterFilter(cluster) {
+		// the cluster is excluded by the filter; clean up anything deployed while it was
+		// still included
+		if err := c.removeWorks(ctx, clusterName); err != nil {
+			return err
+		}
+		return c.removeFinalizer(ctx, addon)
+	}
+
+	if err := c.addFinalizer(ctx, addon); err != nil {
+		return err
+	}
+
+	if err := c.updatePausedCondition(ctx, addon); err != nil {
+		return err
+	}
+	if helpers.AddonPaused(addon) {
+		// reconciliation is paused; leave the deployed works untouched
+		return nil
+	}
+
+	if c.requireAvailable {
+		available, err := c.checkClusterAvailable(ctx, cluster, addon)
+		if err != nil {
+			return err
+		}
+		if !available {
+			// the cluster informer requeues the deploy when the cluster comes online
+			return nil
+		}
+	}
+
+	ready, err := c.checkDependencies(ctx, addon)
+	if err != nil {
+		return err
+	}
+	if !ready {
+		// the addon informer requeues the deploy when a dependency becomes available
+		return nil
+	}
+
+	supported, err := c.checkKubernetesVersion(ctx, cluster, addon)
+	if err != nil {
+		return err
+	}
+	if !supported {
+		// the cluster runs a kubernetes version outside of the supported range; skip the
+		// deploy until the cluster upgrades into range
+		return nil
+	}
+
+	if err := c.checkInstallNamespace(cluster); err != nil {
+		return err
+	}
+
+	config, err := c.getAddonConfig(ctx, addon)
+	if err != nil {
+		return err
+	}
+
+	fingerprint := c.renderFingerprint(cluster, config)
+	unchanged, err := c.renderInputsUnchanged(clusterName, fingerprint)
+	if err != nil {
+		return err
+	}
+	if unchanged {
+		// the declared render inputs did not change; keep the deployed works and only
+		// refresh the mirrored conditions
+		if err := c.updateMappedConditions(ctx, addon); err != nil {
+			return err
+		}
+		if err := c.updateManifestsDegradedCondition(ctx, addon); err != nil {
+			return err
+		}
+		return c.updateWorkAgentCondition(ctx, syncCtx, addon)
+	}
+
+	inputs, err := c.gatherManifestInputs(cluster)
+	if err != nil {
+		return err
+	}
+
+	objects, err := c.renderManifests(cluster, config, inputs)
+	if err := c.updateRenderedCondition(ctx, addon, err); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+
+	objects, err = c.transformManifests(ctx, cluster, addon, objects)
+	if err != nil {
+		return err
+	}
+
+	objects = filterByRequiredClaims(cluster, objects)
+	if len(objects) == 0 {
+		// every manifest was dropped by its required claim; nothing to deploy here
+		return c.removeWorks(ctx, clusterName)
+	}
+
+	objects = c.withInstallNamespace(cluster, objects)
+	objects = c.withAgentServiceAccount(cluster, objects)
+	objects = c.withPodDisruptionBudgets(cluster, objects)
+	objects = c.withHubCAConfigMap(cluster, objects)
+	objects = c.withImagePullSecret(cluster, objects)
+
+	manifests, err := c.buildAgentManifests(cluster, objects)
+	if err != nil {
+		return err
+	}
+	digest := manifestsDigest(manifests)
+
+	existingWorks, err := c.workLister.ManifestWorks(clusterName).List(
+		labels.SelectorFromSet(labels.Set{constants.AddonWorkLabel: c.addonName, constants.AddonWorkTypeLabel: constants.DeployWorkType}))
+	if err != nil {
+		return err
+	}
+
+	proceed, err := c.gateRollout(ctx, syncCtx, addon, digest, existingWorks)
+	if err != nil || !proceed {
+		return err
+	}
+
+	idRecord, err := c.manifestIDRecord(cluster, objects)
+	if err != nil {
+		return err
+	}
+	c.reportDisappearedManifests(existingWorks, idRecord)
+
+	manifests, digest, fingerprint, recreateDigest := c.withRecreatePhase(syncCtx, clusterName, manifests, digest, fingerprint, existingWorks)
+
+	var applyErr error
+	if c.orderedDeploy {
+		applyErr = c.applyOrderedWorks(ctx, syncCtx, clusterName, manifests, digest, fingerprint, idRecord, recreateDigest)
+	} else {
+		work := c.newDeployWork(constants.DeployWorkName(c.addonName), clusterName, manifests, digest, fingerprint, idRecord, recreateDigest)
+		work.Annotations[constants.AddonDeployWorksAnnotation] = work.Name
+		applyErr = c.applyWork(ctx, work)
+	}
+	if err := c.updateOversizedCondition(ctx, addon, applyErr); err != nil {
+		return err
+	}
+	if applyErr != nil {
+		return applyErr
+	}
+	if !c.orderedDeploy {
+		// drop the wave works left behind by a switch away from the ordered deploy; the
+		// ordered path prunes its own obsolete waves once they are all applied
+		if err := c.pruneObsoleteDeployWorks(ctx, clusterName, sets.NewString(constants.DeployWorkName(c.addonName))); err != nil {
+			return err
+		}
+	}
+	if len(existingWorks) == 0 {
+		// the first deploy work of this cluster was just created
+		c.eventRecorder.Eventf("AddonDeployed", "Deployed the agent of addon %q to cluster %q", c.addonName, clusterName)
+	}
+
+	if err := c.updateLastAppliedConfigHash(ctx, addon, config); err != nil {
+		return err
+	}
+
+	if err := c.updateMappedConditions(ctx, addon); err != nil {
+		return err
+	}
+
+	if err := c.updateManifestsDegradedCondition(ctx, addon); err != nil {
+		return err
+	}
+
+	return c.updateWorkAgentCondition(ctx, syncCtx, addon)
+}
+
+// updateLastAppliedConfigHash stamps the hash of the applied addon configuration on the
+// ManagedClusterAddOn after a successful work apply. The write is skipped when the annotation
+// already carries the hash, and the annotation is removed when no configuration is in use.
+func (c *addonDeployController) updateLastAppliedConfigHash(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn, config runtime.Object) error {
+	hash := ""
+	if config != nil {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(config.DeepCopyObject())
+		if err != nil {
+			return err
+		}
+		// the hash covers only the desired state; volatile metadata like the resource
+		// version would change it on every no-op write of the config
+		delete(content, "metadata")
+		delete(content, "status")
+		raw, err := json.Marshal(content)
+		if err != nil {
+			return err
+		}
+		hash = fmt.Sprintf("%x", sha256.Sum256(raw))
+	}
+
+	if addon.Annotations[constants.LastAppliedConfigHashAnnotation] == hash {
+		return nil
+	}
+
+	updated := addon.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	if len(hash) == 0 {
+		delete(updated.Annotations, constants.LastAppliedConfigHashAnnotation)
+	} else {
+		updated.Annotations[constants.LastAppliedConfigHashAnnotation] = hash
+	}
+	_, err := c.addonClient.AddonV1alpha1().ManagedClusterAddOns(addon.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// gatherManifestInputs collects the optional render inputs the addon declares an interest in:
+// every input for an addon implementing AgentAddonWithManifestInputs, or the single input of
+// the one single-input interface it implements
+func (c *addonDeployController) gatherManifestInputs(cluster *clusterv1.ManagedCluster) (agent.ManifestInputs, error) {
+	inputs := agent.ManifestInputs{}
+	_, composed := c.agentAddon.(agent.AgentAddonWithManifestInputs)
+	var err error
+	if _, ok := c.agentAddon.(agent.AgentAddonWithConnectivity); ok || composed {
+		if inputs.Connectivity, err = c.resolveConnectivity(); err != nil {
+			return inputs, err
+		}
+	}
+	if _, ok := c.agentAddon.(agent.AgentAddonWithDeployedDigest); ok || composed {
+		if inputs.DeployedDigest, err = c.deployedDigest(cluster.Name); err != nil {
+			return inputs, err
+		}
+	}
+	if _, ok := c.agentAddon.(agent.AgentAddonWithHubSecrets); ok || composed {
+		if inputs.HubSecrets, err = c.hubSecretInputs(cluster.Name); err != nil {
+			return inputs, err
+		}
+	}
+	if _, ok := c.agentAddon.(agent.AgentAddonWithInstalledAddons); ok || composed {
+		if inputs.InstalledAddons, err = c.installedAddons(cluster.Name); err != nil {
+			return inputs, err
+		}
+	}
+	return inputs, nil
+}
+
+// hubSecretInputs lists the secrets of the cluster namespace matching the selector configured
+// with WithHubSecretInputs, sorted by name for a stable render input
+func (c *addonDeployController) hubSecretInputs(clusterName string) ([]*corev1.Secret, error) {
+	if len(c.secretSelector) == 0 || c.secretLister == nil {
+		return nil, nil
+	}
+	secrets, err := c.secretLister.Secrets(clusterName).List(labels.SelectorFromSet(c.secretSelector))
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(secrets, func(i, j int) bool { return secrets[i].Name < secrets[j].Name })
+	return secrets, nil
+}
+
+// installedAddons lists the sorted names of the ManagedClusterAddOns present in the cluster
+// namespace from the informer cache, including the addon of this controller itself
+func (c *addonDeployController) installedAddons(clusterName string) ([]string, error) {
+	addons, err := c.addonLister.ManagedClusterAddOns(clusterName).List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, addon := range addons {
+		names = append(names, addon.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// resolveConnectivity resolves the connectivity details of the hub. The proxy endpoint is
+// discovered from the configured cluster-proxy config map and left empty when none exists, so
+// the render degrades gracefully on hubs without a proxy.
+func (c *addonDeployController) resolveConnectivity() (agent.ConnectivityInfo, error) {
+	connectivity := agent.ConnectivityInfo{
+		HubAPIServerURL: c.hubAPIServerURL,
+	}
+
+	if len(c.proxyConfigNamespace) == 0 || len(c.proxyConfigName) == 0 {
+		return connectivity, nil
+	}
+
+	configMap, err := c.configMapLister.ConfigMaps(c.proxyConfigNamespace).Get(c.proxyConfigName)
+	switch {
+	case errors.IsNotFound(err):
+		return connectivity, nil
+	case err != nil:
+		return connectivity, err
+	}
+
+	connectivity.ProxyURL = configMap.Data[constants.ProxyConfigURLKey]
+	return connectivity, nil
+}
+
+// deployedDigest returns the manifest digest annotated on the existing deploy works of the
+// addon in the given cluster namespace, or an empty string on a fresh install
+func (c *addonDeployController) deployedDigest(clusterName string) (string, error) {
+	works, err := c.workLister.ManifestWorks(clusterName).List(
+		labels.SelectorFromSet(labels.Set{constants.AddonWorkLabel: c.addonName, constants.AddonWorkTypeLabel: constants.DeployWorkType}))
+	if err != nil {
+		return "", err
+	}
+
+	for _, work := range works {
+		if digest, ok := work.Annotations[constants.AddonManifestDigestAnnotation]; ok {
+			return digest, nil
+		}
+	}
+	return "", nil
+}
+
+// WaitingForClusterAvailable is the condition type reporting that the deploy is deferred until
+// the managed cluster reports the Available condition.
+const WaitingForClusterAvailable = "WaitingForClusterAvailable"
+
+// checkClusterAvailable checks whether the managed cluster reports the Available condition and
+// reports a waiting condition on the addon while it does not. Deploying to an offline cluster
+// only creates works that sit unapplied and confuse the status; deferring until the klusterlet
+// can receive works keeps the addon status honest.
+func (c *addonDeployController) checkClusterAvailable(ctx context.Context, cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) (bool, error) {
+	available := meta.IsStatusConditionTrue(cluster.Status.Conditions, clusterv1.ManagedClusterConditionAvailable)
+
+	cond := metav1.Condition{
+		Type:    WaitingForClusterAvailable,
+		Status:  metav1.ConditionFalse,
+		Reason:  "ClusterAvailable",
+		Message: "The managed cluster is available",
+	}
+	if !available {
+		cond = metav1.Condition{
+			Type:    WaitingForClusterAvailable,
+			Status:  metav1.ConditionTrue,
+			Reason:  "ClusterUnavailable",
+			Message: "The deploy is deferred until the managed cluster becomes available",
+		}
+	}
+
+	if _, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond)); err != nil {
+		return false, err
+	}
+	return available, nil
+}
+
+// UnsupportedKubernetesVersion is the condition type reporting that the managed cluster runs a
+// Kubernetes version outside of the range the agent supports.
+const UnsupportedKubernetesVersion = "UnsupportedKubernetesVersion"
+
+// checkKubernetesVersion checks the kubernetes version of the managed cluster against the range
+// the agent supports, if the agent addon declares one, and reports the outcome on the addon. It
+// returns whether the deploy should proceed. A cluster that has not reported its version yet is
+// treated as supported, so a fresh cluster is not held back on a missing status.
+func (c *addonDeployController) checkKubernetesVersion(ctx context.Context, cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn) (bool, error) {
+	versionedAgentAddon, ok := c.agentAddon.(agent.AgentAddonWithSupportedVersions)
+	if !ok {
+		return true, nil
+	}
+
+	version := agent.ClusterKubernetesVersion(cluster)
+	if len(version) == 0 {
+		return true, nil
+	}
+
+	supported, err := versionedAgentAddon.SupportedKubernetesVersions().Includes(version)
+	if err != nil {
+		return false, err
+	}
+
+	cond := metav1.Condition{
+		Type:    UnsupportedKubernetesVersion,
+		Status:  metav1.ConditionFalse,
+		Reason:  "KubernetesVersionSupported",
+		Message: fmt.Sprintf("The kubernetes version %s of the cluster is supported by the agent", version),
+	}
+	if !supported {
+		cond = metav1.Condition{
+			Type:    UnsupportedKubernetesVersion,
+			Status:  metav1.ConditionTrue,
+			Reason:  "KubernetesVersionUnsupported",
+			Message: fmt.Sprintf("The kubernetes version %s of the cluster is outside of the range supported by the agent", version),
+		}
+	}
+
+	if _, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond)); err != nil {
+		return false, err
+	}
+	return supported, nil
+}
+
+// Paused is the condition type reporting that the reconciliation of the addon is paused with
+// the paused annotation.
+const Paused = "Paused"
+
+// updatePausedCondition reports on the addon whether its reconciliation is paused, so an
+// operator can tell from the status that the controller is intentionally standing still.
+func (c *addonDeployController) updatePausedCondition(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+	cond := metav1.Condition{
+		Type:    Paused,
+		Status:  metav1.ConditionFalse,
+		Reason:  "AddonReconciling",
+		Message: "The addon is reconciled normally",
+	}
+	if helpers.AddonPaused(addon) {
+		cond = metav1.Condition{
+			Type:    Paused,
+			Status:  metav1.ConditionTrue,
+			Reason:  "AddonPaused",
+			Message: fmt.Sprintf("The addon reconciliation is paused with the %s annotation", constants.AddonPausedAnnotation),
+		}
+	}
+
+	_, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond))
+	return err
+}
+
+// ManifestRendered is the condition type reporting whether AgentManifests rendered the agent
+// manifests successfully.
+const ManifestRendered = "ManifestRendered"
+
+// updateRenderedCondition reports the outcome of AgentManifests on the addon. A structured
+// agent.RenderError carries the failing file, manifest index and field, which its Error method
+// renders into the condition message, so authors can see what broke without reading the
+// controller log.
+func (c *addonDeployController) updateRenderedCondition(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn, renderErr error) error {
+	cond := metav1.Condition{
+		Type:    ManifestRendered,
+		Status:  metav1.ConditionTrue,
+		Reason:  "RenderingSucceeded",
+		Message: "The agent manifests are rendered successfully",
+	}
+	if renderErr != nil {
+		cond = metav1.Condition{
+			Type:    ManifestRendered,
+			Status:  metav1.ConditionFalse,
+			Reason:  "RenderingFailed",
+			Message: fmt.Sprintf("Failed to render the agent manifests: %v", renderErr),
+		}
+	}
+
+	_, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond))
+	return err
+}
+
+// Uninstalling is the condition type reporting the teardown progress of a deleted addon, with
+// the works still being removed in its message so a stuck uninstall shows what remains.
+const Uninstalling = "Uninstalling"
+
+// uninstall tears down the deploy works of a deleted addon, reporting the progress with the
+// Uninstalling condition. The finalizer is only removed, with an AddonUninstalled event as the
+// completion signal, once every work is gone; until then the work informer requeues the addon
+// as the deletions complete.
+func (c *addonDeployController) uninstall(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+	if err := c.removeWorks(ctx, addon.Namespace); err != nil {
+		return err
+	}
+
+	remaining, err := c.workLister.ManifestWorks(addon.Namespace).List(
+		labels.SelectorFromSet(labels.Set{constants.AddonWorkLabel: c.addonName, constants.AddonWorkTypeLabel: constants.DeployWorkType}))
+	if err != nil {
+		return err
+	}
+
+	if len(remaining) > 0 {
+		names := []string{}
+		for _, work := range remaining {
+			names = append(names, work.Name)
+		}
+		cond := metav1.Condition{
+			Type:    Uninstalling,
+			Status:  metav1.ConditionTrue,
+			Reason:  "RemovingDeployWorks",
+			Message: fmt.Sprintf("Waiting for the removal of the following manifestworks: %s", strings.Join(names, ", ")),
+		}
+		_, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond))
+		return err
+	}
+
+	c.eventRecorder.Eventf("AddonUninstalled", "Uninstalled the agent of addon %q from cluster %q", c.addonName, addon.Namespace)
+	return c.removeFinalizer(ctx, addon)
+}
+
+// addFinalizer adds the deploy finalizer on the addon if it is not there yet
+func (c *addonDeployController) addFinalizer(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+	for _, finalizer := range addon.Finalizers {
+		if finalizer == c.finalizerName {
+			return nil
+		}
+	}
+
+	updated := addon.DeepCopy()
+	updated.Finalizers = append(updated.Finalizers, c.finalizerName)
+	_, err := c.addonClient.AddonV1alpha1().ManagedClusterAddOns(addon.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// removeFinalizer removes the deploy finalizer from the addon after its works are cleaned up
+func (c *addonDeployController) removeFinalizer(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+	finalizers := []string{}
+	found := false
+	for _, finalizer := range addon.Finalizers {
+		if finalizer == c.finalizerName {
+			found = true
+			continue
+		}
+		finalizers = append(finalizers, finalizer)
+	}
+	if !found {
+		return nil
+	}
+
+	updated := addon.DeepCopy()
+	updated.Finalizers = finalizers
+	_, err := c.addonClient.AddonV1alpha1().ManagedClusterAddOns(addon.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// getAddonConfig returns the addon configuration resource: the config map of the cluster
+// namespace if the config map source is selected on the manager, or the CR referenced by the
+// addOnConfiguration coordinates of the addon. When no configuration resolves the built-in
+// default configured on the manager is returned instead, so a resolved configuration always
+// overrides the default; without one, nil is returned.
+func (c *addonDeployController) getAddonConfig(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn) (runtime.Object, error) {
+	config, err := c.resolveAddonConfig(ctx, addon)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil && c.defaultConfig != nil {
+		// deep copy per render so no install can mutate the default of another
+		return c.defaultConfig.DeepCopyObject(), nil
+	}
+	return config, nil
+}
+
+// resolveAddonConfig resolves the configuration resource of the addon, returning nil when
+// none is referenced or found
+func (c *addonDeployController) resolveAddonConfig(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn) (runtime.Object, error) {
+	if len(c.configMapName) != 0 && c.configMapLister != nil {
+		configMap, err := c.configMapLister.ConfigMaps(addon.Namespace).Get(c.configMapName)
+		switch {
+		case errors.IsNotFound(err):
+			return nil, nil
+		case err != nil:
+			return nil, err
+		}
+		config := runtime.Object(configMap)
+		if c.configDefaulter != nil {
+			config = c.configDefaulter(config.DeepCopyObject())
+		}
+		return config, nil
+	}
+
+	gvr := c.agentAddon.GetAgentAddonOptions().AddonConfigGVR
+	if gvr.Empty() {
+		return nil, nil
+	}
+
+	if len(c.configSelector) > 0 {
+		return c.getAddonConfigBySelector(ctx, addon, gvr)
+	}
+
+	crName := addon.Status.AddOnConfiguration.CRName
+	if len(crName) == 0 {
+		return nil, nil
+	}
+
+	config, err := c.dynamicClient.Resource(gvr).Namespace(addon.Namespace).Get(ctx, crName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+
+	if c.configDefaulter != nil {
+		// default on a deep copy so the original object is never mutated
+		return c.configDefaulter(config.DeepCopyObject()), nil
+	}
+
+	return config, nil
+}
+
+// getAddonConfigBySelector resolves the config CR by the configured label selector in the
+// cluster namespace, decoupling the config naming from the addOnConfiguration coordinates,
+// e.g. for templated or generated configs. Exactly one CR must match: zero matches and
+// ambiguous matches are both surfaced as errors instead of silently rendering without, or
+// with an arbitrary, configuration.
+func (c *addonDeployController) getAddonConfigBySelector(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn, gvr schema.GroupVersionResource) (runtime.Object, error) {
+	configList, err := c.dynamicClient.Resource(gvr).Namespace(addon.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(c.configSelector).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(configList.Items) {
+	case 0:
+		return nil, fmt.Errorf("no %s in namespace %s matches the config selector %v", gvr.Resource, addon.Namespace, c.configSelector)
+	case 1:
+	default:
+		return nil, fmt.Errorf("%d %s in namespace %s match the config selector %v, expected exactly one", len(configList.Items), gvr.Resource, addon.Namespace, c.configSelector)
+	}
+
+	config := runtime.Object(&configList.Items[0])
+	if c.configDefaulter != nil {
+		return c.configDefaulter(config.DeepCopyObject()), nil
+	}
+	return config, nil
+}
+
+// checkInstallNamespace guards against deploying the agent into a protected system namespace.
+// The resolved install namespace is checked per cluster since a namespace function may pick a
+// different namespace for each one; the static namespace is additionally rejected at startup
+// by the manager validation.
+func (c *addonDeployController) checkInstallNamespace(cluster *clusterv1.ManagedCluster) error {
+	namespace := c.agentAddon.GetAgentAddonOptions().InstallNamespace(cluster)
+	if helpers.IsProtectedNamespace(namespace) && !c.allowedSystemNS.Has(namespace) {
+		return fmt.Errorf("the install namespace %q resolved for cluster %q is a protected system namespace; allowlist it with WithSystemNamespaceAllowlist if this is intended", namespace, cluster.Name)
+	}
+	return nil
+}
+
+// withInstallNamespace prepends a manifest for the install namespace of the agent when the
+// namespace creation is enabled, so that the namespace is created with the addon and removed
+// by the work agent together with the rest of the deploy work on addon removal. The namespace
+// is not injected if the author already ships it, in which case the author owns its lifecycle.
+func (p *ManifestPipeline) withInstallNamespace(cluster *clusterv1.ManagedCluster, objects []runtime.Object) []runtime.Object {
+	if !p.createNamespace {
+		return objects
+	}
+	installNamespace := p.agentAddon.GetAgentAddonOptions().InstallNamespace(cluster)
+	if len(installNamespace) == 0 {
+		return objects
+	}
+
+	for _, object := range objects {
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			continue
+		}
+		if unstructuredObject, ok := object.(*unstructured.Unstructured); ok {
+			if unstructuredObject.GetKind() == "Namespace" && accessor.GetName() == installNamespace {
+				p.mergeNamespaceLabels(accessor)
+				return objects
+			}
+			continue
+		}
+		if namespaceObject, ok := object.(*corev1.Namespace); ok && namespaceObject.Name == installNamespace {
+			p.mergeNamespaceLabels(namespaceObject)
+			return objects
+		}
+	}
+
+	labels := map[string]string{}
+	for key, value := range p.namespaceLabels {
+		labels[key] = value
+	}
+	labels[constants.AddonLabel] = p.addonName
+	namespace := &corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Namespace",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   installNamespace,
+			Labels: labels,
+		},
+	}
+	return append([]runtime.Object{namespace}, objects...)
+}
+
+// mergeNamespaceLabels merges the labels configured with WithInstallNamespaceLabels into an
+// author-shipped install namespace manifest, so the namespace participates in label-keyed
+// policies either way. A label the author set with the same key wins.
+func (p *ManifestPipeline) mergeNamespaceLabels(namespace metav1.Object) {
+	if len(p.namespaceLabels) == 0 {
+		return
+	}
+	labels := namespace.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for key, value := range p.namespaceLabels {
+		if _, ok := labels[key]; !ok {
+			labels[key] = value
+		}
+	}
+	namespace.SetLabels(labels)
+}
+
+// withAgentServiceAccount appends a manifest for the standardized agent service account when
+// one is configured on the manager, so the identity the agent pods run under exists on every
+// managed cluster without the author shipping it. A service account the author ships with the
+// same coordinates wins and is left untouched.
+func (p *ManifestPipeline) withAgentServiceAccount(cluster *clusterv1.ManagedCluster, objects []runtime.Object) []runtime.Object {
+	if len(p.serviceAccountName) == 0 {
+		return objects
+	}
+	namespace := p.serviceAccountNS
+	if len(namespace) == 0 {
+		namespace = p.agentAddon.GetAgentAddonOptions().InstallNamespace(cluster)
+	}
+	if len(namespace) == 0 {
+		return objects
+	}
+
+	for _, object := range objects {
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			continue
+		}
+		if accessor.GetName() != p.serviceAccountName || accessor.GetNamespace() != namespace {
+			continue
+		}
+		if unstructuredObject, ok := object.(*unstructured.Unstructured); ok {
+			if unstructuredObject.GetKind() == "ServiceAccount" {
+				return objects
+			}
+			continue
+		}
+		if _, ok := object.(*corev1.ServiceAccount); ok {
+			return objects
+		}
+	}
+
+	serviceAccount := &corev1.ServiceAccount{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ServiceAccount",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.serviceAccountName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				constants.AddonLabel: p.addonName,
+			},
+		},
+	}
+	return append(objects, serviceAccount)
+}
+
+// withHubCAConfigMap appends a config map carrying the hub CA bundle when one is configured
+// on the manager, so the agent can mount the same trust store the bootstrap kubeconfig was
+// assembled from instead of the author wiring the CA material through ad hop. A config map
+// the author ships with the same coordinates wins and is left untouched.
+func (p *ManifestPipeline) withHubCAConfigMap(cluster *clusterv1.ManagedCluster, objects []runtime.Object) []runtime.Object {
+	if len(p.hubCAConfigMapName) == 0 || len(p.hubCABundle) == 0 {
+		return objects
+	}
+	namespace := p.agentAddon.GetAgentAddonOptions().InstallNamespace(cluster)
+	if len(namespace) == 0 {
+		return objects
+	}
+
+	for _, object := range objects {
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			continue
+		}
+		if accessor.GetName() != p.hubCAConfigMapName || accessor.GetNamespace() != namespace {
+			continue
+		}
+		if unstructuredObject, ok := object.(*unstructured.Unstructured); ok {
+			if unstructuredObject.GetKind() == "ConfigMap" {
+				return objects
+			}
+			continue
+		}
+		if _, ok := object.(*corev1.ConfigMap); ok {
+			return objects
+		}
+	}
+
+	configMap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.hubCAConfigMapName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				constants.AddonLabel: p.addonName,
+			},
+		},
+		Data: map[string]string{
+			constants.HubCABundleKey: string(p.hubCABundle),
+		},
+	}
+	return append(objects, configMap)
+}
+
+// withImagePullSecret appends a manifest for the image pull secret when one is configured on
+// the manager, so agents pulled from a private registry find their registry credential in the
+// install namespace without the author shipping it. The reference in the pod specs is injected
+// by the paired pod spec override; a secret the author ships with the same coordinates wins.
+func (p *ManifestPipeline) withImagePullSecret(cluster *clusterv1.ManagedCluster, objects []runtime.Object) []runtime.Object {
+	if len(p.pullSecretName) == 0 || len(p.pullSecretData) == 0 {
+		return objects
+	}
+	namespace := p.agentAddon.GetAgentAddonOptions().InstallNamespace(cluster)
+	if len(namespace) == 0 {
+		return objects
+	}
+
+	for _, object := range objects {
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			continue
+		}
+		if accessor.GetName() != p.pullSecretName || accessor.GetNamespace() != namespace {
+			continue
+		}
+		if unstructuredObject, ok := object.(*unstructured.Unstructured); ok {
+			if unstructuredObject.GetKind() == "Secret" {
+				return objects
+			}
+			continue
+		}
+		if _, ok := object.(*corev1.Secret); ok {
+			return objects
+		}
+	}
+
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      p.pullSecretName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				constants.AddonLabel: p.addonName,
+			},
+		},
+		Type: corev1.SecretTypeDockerConfigJson,
+		Data: map[string][]byte{
+			corev1.DockerConfigJsonKey: p.pullSecretData,
+		},
+	}
+	return append(objects, secret)
+}
+
+// agentManifest is a rendered agent manifest to be packaged into a deploy manifestwork
+type agentManifest struct {
+	wave     int
+	manifest workapiv1.Manifest
+}
+
+// buildAgentManifests converts the rendered objects into work manifests with the registered
+// overrides and the provenance annotations applied
+func (p *ManifestPipeline) buildAgentManifests(cluster *clusterv1.ManagedCluster, objects []runtime.Object) ([]agentManifest, error) {
+	manifests := []agentManifest{}
+	for _, object := range objects {
+		unstructuredObject, err := toUnstructured(object)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, override := range p.overrides {
+			if err := override(unstructuredObject); err != nil {
+				return nil, err
+			}
+		}
+
+		if p.provenance {
+			p.stampProvenance(unstructuredObject, cluster.Name)
+		}
+
+		if err := p.validateManifestScope(cluster, unstructuredObject); err != nil {
+			return nil, err
+		}
+
+		wave, err := manifestWave(unstructuredObject)
+		if err != nil {
+			return nil, err
+		}
+
+		rawObject, err := unstructuredObject.MarshalJSON()
+		if err != nil {
+			return nil, err
+		}
+
+		manifests = append(manifests, agentManifest{
+			wave:     wave,
+			manifest: workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: rawObject}},
+		})
+	}
+	return manifests, nil
+}
+
+// stampProvenance annotates the manifest with the addon that ships it and the cluster it is
+// deployed for. The values are deterministic, so the annotations never cause spurious work
+// updates.
+func (p *ManifestPipeline) stampProvenance(object *unstructured.Unstructured, clusterName string) {
+	annotations := object.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[constants.OwnerAddonAnnotation] = p.addonName
+	annotations[constants.SourceClusterAnnotation] = clusterName
+	object.SetAnnotations(annotations)
+}
+
+// newDeployWork returns a manifestwork deploying the given agent manifests on the managed cluster
+func (c *addonDeployController) newDeployWork(workName, clusterName string, manifests []agentManifest, digest, fingerprint, idRecord, recreateDigest string) *workapiv1.ManifestWork {
+	workManifests := []workapiv1.Manifest{}
+	for _, manifest := range manifests {
+		workManifests = append(workManifests, manifest.manifest)
+	}
+
+	annotations := map[string]string{
+		constants.AddonManifestDigestAnnotation: digest,
+	}
+	if len(fingerprint) > 0 {
+		annotations[constants.AddonRenderInputsAnnotation] = fingerprint
+	}
+	if len(c.workExecutor) > 0 {
+		annotations[constants.WorkExecutorAnnotation] = c.workExecutor
+	}
+	if len(idRecord) > 0 {
+		annotations[constants.AddonManifestIDsAnnotation] = idRecord
+	}
+	if len(recreateDigest) > 0 {
+		annotations[constants.AddonRecreateDigestAnnotation] = recreateDigest
+	}
+
+	return &workapiv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        workName,
+			Namespace:   clusterName,
+			Annotations: annotations,
+			Labels: map[string]string{
+				constants.AddonWorkLabel:     c.addonName,
+				constants.AddonWorkTypeLabel: constants.DeployWorkType,
+			},
+		},
+		Spec: workapiv1.ManifestWorkSpec{
+			Workload: workapiv1.ManifestsTemplate{
+				Manifests: workManifests,
+			},
+		},
+	}
+}
+
+// applyWork creates the required manifestwork if it does not exist, and updates the existing
+// manifestwork if its spec drifts from the required one. When a work apply timeout is set the
+// sync blocks until the work reports the Applied condition, so a failed rollout surfaces as a
+// sync error instead of only in the mirrored work status.
+func (c *addonDeployController) applyWork(ctx context.Context, required *workapiv1.ManifestWork) error {
+	if err := c.checkWorkSize(required); err != nil {
+		return err
+	}
+
+	_, _, err := helpers.ApplyWork(ctx, c.workClient, c.workLister, c.eventRecorder, c.workWriteLimiter, required)
+	if err != nil {
+		return err
+	}
+	if c.workApplyTimeout <= 0 {
+		return nil
+	}
+	return c.waitForWorkApplied(ctx, required.Namespace, required.Name)
+}
+
+// workAppliedPollInterval is the interval at which the work status is polled while waiting for
+// the Applied condition
+const workAppliedPollInterval = 2 * time.Second
+
+// waitForWorkApplied polls the manifestwork until it reports the Applied condition, up to the
+// configured work apply timeout. The poll stops early when the context is cancelled.
+func (c *addonDeployController) waitForWorkApplied(ctx context.Context, namespace, name string) error {
+	timeoutCtx, cancel := context.WithTimeout(ctx, c.workApplyTimeout)
+	defer cancel()
+
+	err := wait.PollImmediateUntil(workAppliedPollInterval, func() (bool, error) {
+		work, err := c.workClient.WorkV1().ManifestWorks(namespace).Get(timeoutCtx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		return meta.IsStatusConditionTrue(work.Status.Conditions, workapiv1.WorkApplied), nil
+	}, timeoutCtx.Done())
+	if err != nil {
+		return fmt.Errorf("manifestwork %s/%s was not applied within %s: %v", namespace, name, c.workApplyTimeout, err)
+	}
+	return nil
+}
+
+// removeWorks deletes all deploy manifestworks of the addon in the given cluster namespace
+func (c *addonDeployController) removeWorks(ctx context.Context, clusterName string) error {
+	if c.rolloutGate != nil {
+		// a cluster going away must not hold its rollout slot forever
+		c.rolloutGate.release(clusterName)
+	}
+	works, err := c.workLister.ManifestWorks(clusterName).List(
+		labels.SelectorFromSet(labels.Set{constants.AddonWorkLabel: c.addonName, constants.AddonWorkTypeLabel: constants.DeployWorkType}))
+	if err != nil {
+		return err
+	}
+
+	removed := 0
+	for _, work := range works {
+		err := c.workClient.WorkV1().ManifestWorks(clusterName).Delete(ctx, work.Name, helpers.DeleteOptionsWithPropagation(c.deletePropagation))
+		switch {
+		case errors.IsNotFound(err):
+			continue
+		case err != nil:
+			return err
+		}
+		c.eventRecorder.Eventf("AddonDeployWorkDeleted", "Deleted manifestwork %s/%s", clusterName, work.Name)
+		removed++
+	}
+	if removed > 0 {
+		// only mark the teardown when something was actually removed, so resyncs over an
+		// already clean cluster stay silent
+		c.eventRecorder.Eventf("AddonRemoved", "Removed the agent of addon %q from cluster %q", c.addonName, clusterName)
+	}
+	return nil
+}
+
+// pruneObsoleteDeployWorks deletes the deploy manifestworks of the addon in the given cluster
+// namespace whose name is not in the kept set. A wave emptied by a render change or a toggle
+// of the ordered deploy leaves its old work behind otherwise, keeping manifests deployed that
+// the addon no longer renders.
+func (c *addonDeployController) pruneObsoleteDeployWorks(ctx context.Context, clusterName string, keep sets.String) error {
+	works, err := c.workLister.ManifestWorks(clusterName).List(
+		labels.SelectorFromSet(labels.Set{constants.AddonWorkLabel: c.addonName, constants.AddonWorkTypeLabel: constants.DeployWorkType}))
+	if err != nil {
+		return err
+	}
+
+	for _, work := range works {
+		if keep.Has(work.Name) {
+			continue
+		}
+		err := c.workClient.WorkV1().ManifestWorks(clusterName).Delete(ctx, work.Name, helpers.DeleteOptionsWithPropagation(c.deletePropagation))
+		switch {
+		case errors.IsNotFound(err):
+			continue
+		case err != nil:
+			return err
+		}
+		c.eventRecorder.Eventf("AddonDeployWorkDeleted", "Deleted obsolete manifestwork %s/%s", clusterName, work.Name)
+	}
+	return nil
+}
+
+// executorSubject renders the "<namespace>/<name>" executor subject of the configured work
+// executor service account, or an empty string when none is configured
+func executorSubject(namespace, name string) string {
+	if len(namespace) == 0 || len(name) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
+
+// manifestsDigest returns the digest of the raw agent manifests, annotated on the deploy works
+// so the deployed version can be recognized on later reconciles
+func manifestsDigest(manifests []agentManifest) string {
+	hash := sha256.New()
+	for _, manifest := range manifests {
+		hash.Write(manifest.manifest.Raw)
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// toUnstructured converts the given object into an unstructured object
+func toUnstructured(object runtime.Object) (*unstructured.Unstructured, error) {
+	if unstructuredObject, ok := object.(*unstructured.Unstructured); ok {
+		return unstructuredObject.DeepCopy(), nil
+	}
+
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+	if err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: content}, nil
+}
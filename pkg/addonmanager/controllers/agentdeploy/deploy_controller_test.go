@@ -0,0 +1,297 @@
+package agentdeploy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	testinghelpers "github.com/open-cluster-management/addon-framework/pkg/helpers/testing"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	fakeaddon "github.com/open-cluster-management/api/client/addon/clientset/versioned/fake"
+	addoninformers "github.com/open-cluster-management/api/client/addon/informers/externalversions"
+	fakecluster "github.com/open-cluster-management/api/client/cluster/clientset/versioned/fake"
+	clusterinformers "github.com/open-cluster-management/api/client/cluster/informers/externalversions"
+	fakework "github.com/open-cluster-management/api/client/work/clientset/versioned/fake"
+	workinformers "github.com/open-cluster-management/api/client/work/informers/externalversions"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"github.com/openshift/library-go/pkg/operator/events"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	kubeinformers "k8s.io/client-go/informers"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	deployTestAddonName   = "test"
+	deployTestClusterName = "cluster1"
+)
+
+func newTestAddon(finalizers []string, annotations map[string]string) *addonapiv1alpha1.ManagedClusterAddOn {
+	return &addonapiv1alpha1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        deployTestAddonName,
+			Namespace:   deployTestClusterName,
+			Finalizers:  finalizers,
+			Annotations: annotations,
+		},
+	}
+}
+
+func newTestCluster() *clusterv1.ManagedCluster {
+	return &clusterv1.ManagedCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: deployTestClusterName},
+	}
+}
+
+func newAgentConfigMap() *corev1.ConfigMap {
+	return &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "agent-config",
+			Namespace: "default",
+		},
+		Data: map[string]string{"key": "value"},
+	}
+}
+
+// newDeployController builds the controller under test directly with fake clients, its listers
+// fed from the informer stores
+func newDeployController(
+	t *testing.T,
+	agentAddon *testinghelpers.FakeAgentAddon,
+	addon *addonapiv1alpha1.ManagedClusterAddOn,
+	cluster *clusterv1.ManagedCluster,
+	works ...*workapiv1.ManifestWork,
+) (*addonDeployController, *fakeaddon.Clientset, *fakework.Clientset, cache.Store) {
+	addonObjects := []runtime.Object{}
+	if addon != nil {
+		addonObjects = append(addonObjects, addon)
+	}
+	addonClient := fakeaddon.NewSimpleClientset(addonObjects...)
+	addonInformerFactory := addoninformers.NewSharedInformerFactory(addonClient, 0)
+	addonInformer := addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns()
+	if addon != nil {
+		if err := addonInformer.Informer().GetStore().Add(addon); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clusterInformerFactory := clusterinformers.NewSharedInformerFactory(fakecluster.NewSimpleClientset(), 0)
+	clusterInformer := clusterInformerFactory.Cluster().V1().ManagedClusters()
+	if cluster != nil {
+		if err := clusterInformer.Informer().GetStore().Add(cluster); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	workObjects := []runtime.Object{}
+	for _, work := range works {
+		workObjects = append(workObjects, work)
+	}
+	workClient := fakework.NewSimpleClientset(workObjects...)
+	workInformerFactory := workinformers.NewSharedInformerFactory(workClient, 0)
+	workInformer := workInformerFactory.Work().V1().ManifestWorks()
+	for _, work := range works {
+		if err := workInformer.Informer().GetStore().Add(work); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &addonDeployController{
+		ManifestPipeline: NewManifestPipeline(
+			deployTestAddonName, agentAddon, nil, nil, nil, false, nil, "", "", "", "", nil, nil, "", false),
+		addonClient:          addonClient,
+		workClient:           workClient,
+		addonLister:          addonInformer.Lister(),
+		clusterLister:        clusterInformer.Lister(),
+		workLister:           workInformer.Lister(),
+		clusterNamespaces:    sets.NewString(),
+		finalizerName:        constants.AddonDeployFinalizer,
+		allowedSystemNS:      sets.NewString(),
+		deletePropagation:    metav1.DeletePropagationBackground,
+		maxWorkSize:          DefaultMaxManifestWorkSize,
+		workAgentGracePeriod: DefaultWorkAgentGracePeriod,
+		eventRecorder:        events.NewInMemoryRecorder("test"),
+	}, addonClient, workClient, workInformer.Informer().GetStore()
+}
+
+func newDeployWorkFixture() *workapiv1.ManifestWork {
+	return &workapiv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      constants.DeployWorkName(deployTestAddonName),
+			Namespace: deployTestClusterName,
+			Labels: map[string]string{
+				constants.AddonWorkLabel:     deployTestAddonName,
+				constants.AddonWorkTypeLabel: constants.DeployWorkType,
+			},
+		},
+	}
+}
+
+func syncDeployController(t *testing.T, controller *addonDeployController) error {
+	t.Helper()
+	syncCtx := testinghelpers.NewFakeSyncContext(t, deployTestClusterName+"/"+deployTestAddonName)
+	return controller.sync(context.TODO(), syncCtx)
+}
+
+func getAddon(t *testing.T, addonClient *fakeaddon.Clientset) *addonapiv1alpha1.ManagedClusterAddOn {
+	t.Helper()
+	addon, err := addonClient.AddonV1alpha1().ManagedClusterAddOns(deployTestClusterName).Get(
+		context.TODO(), deployTestAddonName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return addon
+}
+
+func TestSyncRemovesFinalizerOnAbsentCluster(t *testing.T) {
+	agentAddon := testinghelpers.NewFakeAgentAddon(deployTestAddonName, []runtime.Object{newAgentConfigMap()}, nil)
+	addon := newTestAddon([]string{constants.AddonDeployFinalizer}, nil)
+	work := newDeployWorkFixture()
+	controller, addonClient, workClient, _ := newDeployController(t, agentAddon, addon, nil, work)
+
+	if err := syncDeployController(t, controller); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := workClient.WorkV1().ManifestWorks(deployTestClusterName).Get(context.TODO(), work.Name, metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected the deploy work to be removed for the absent cluster, got %v", err)
+	}
+	if finalizers := getAddon(t, addonClient).Finalizers; len(finalizers) != 0 {
+		t.Errorf("expected the finalizer to be removed for the absent cluster, got %v", finalizers)
+	}
+}
+
+func TestSyncRecreatesDeletedWork(t *testing.T) {
+	agentAddon := testinghelpers.NewFakeAgentAddon(deployTestAddonName, []runtime.Object{newAgentConfigMap()}, nil)
+	// no work is seeded: the deploy work was deleted out-of-band
+	controller, _, workClient, _ := newDeployController(t, agentAddon, newTestAddon(nil, nil), newTestCluster())
+
+	if err := syncDeployController(t, controller); err != nil {
+		t.Fatal(err)
+	}
+
+	work, err := workClient.WorkV1().ManifestWorks(deployTestClusterName).Get(
+		context.TODO(), constants.DeployWorkName(deployTestAddonName), metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("expected the deploy work to be recreated: %v", err)
+	}
+	if len(work.Spec.Workload.Manifests) != 1 {
+		t.Errorf("expected 1 manifest in the recreated work, got %d", len(work.Spec.Workload.Manifests))
+	}
+}
+
+func TestSyncPausedAddon(t *testing.T) {
+	agentAddon := testinghelpers.NewFakeAgentAddon(deployTestAddonName, []runtime.Object{newAgentConfigMap()}, nil)
+	addon := newTestAddon(nil, map[string]string{constants.AddonPausedAnnotation: "true"})
+	controller, addonClient, workClient, _ := newDeployController(t, agentAddon, addon, newTestCluster())
+
+	if err := syncDeployController(t, controller); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := workClient.WorkV1().ManifestWorks(deployTestClusterName).Get(
+		context.TODO(), constants.DeployWorkName(deployTestAddonName), metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected no work mutation while the addon is paused, got %v", err)
+	}
+	testinghelpers.AssertAddonCondition(t, getAddon(t, addonClient).Status.Conditions, metav1.Condition{
+		Type:   Paused,
+		Status: metav1.ConditionTrue,
+		Reason: "AddonPaused",
+	})
+}
+
+func TestSyncStampsLastAppliedConfigHash(t *testing.T) {
+	agentAddon := testinghelpers.NewFakeAgentAddon(deployTestAddonName, []runtime.Object{newAgentConfigMap()}, nil)
+	controller, addonClient, workClient, workStore := newDeployController(t, agentAddon, newTestAddon(nil, nil), newTestCluster())
+
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "addon-config", Namespace: deployTestClusterName},
+		Data:       map[string]string{"image": "quay.io/test/agent:v1"},
+	}
+	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(fakekube.NewSimpleClientset(), 0)
+	configMapInformer := kubeInformerFactory.Core().V1().ConfigMaps()
+	if err := configMapInformer.Informer().GetStore().Add(configMap); err != nil {
+		t.Fatal(err)
+	}
+	controller.configMapName = "addon-config"
+	controller.configMapLister = configMapInformer.Lister()
+
+	if err := syncDeployController(t, controller); err != nil {
+		t.Fatal(err)
+	}
+
+	hash := getAddon(t, addonClient).Annotations[constants.LastAppliedConfigHashAnnotation]
+	if len(hash) == 0 {
+		t.Fatal("expected the last applied config hash annotation to be stamped")
+	}
+
+	// the annotation is idempotent: a second sync over the unchanged config keeps the hash
+	work, err := workClient.WorkV1().ManifestWorks(deployTestClusterName).Get(
+		context.TODO(), constants.DeployWorkName(deployTestAddonName), metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := workStore.Add(work); err != nil {
+		t.Fatal(err)
+	}
+	if err := syncDeployController(t, controller); err != nil {
+		t.Fatal(err)
+	}
+	if rehash := getAddon(t, addonClient).Annotations[constants.LastAppliedConfigHashAnnotation]; rehash != hash {
+		t.Errorf("expected a stable config hash across syncs, got %q and %q", hash, rehash)
+	}
+}
+
+func TestSyncRenderError(t *testing.T) {
+	agentAddon := testinghelpers.NewFakeAgentAddon(deployTestAddonName, nil, errors.New("render failed"))
+	controller, addonClient, _, _ := newDeployController(t, agentAddon, newTestAddon(nil, nil), newTestCluster())
+
+	if err := syncDeployController(t, controller); err == nil {
+		t.Fatal("expected the render error to surface as a sync error")
+	}
+
+	if agentAddon.ManifestsCalled != 1 {
+		t.Errorf("expected AgentManifests to be called once, got %d", agentAddon.ManifestsCalled)
+	}
+	testinghelpers.AssertAddonCondition(t, getAddon(t, addonClient).Status.Conditions, metav1.Condition{
+		Type:   ManifestRendered,
+		Status: metav1.ConditionFalse,
+		Reason: "RenderingFailed",
+	})
+}
+
+func TestSyncRolloutGateQueuesCluster(t *testing.T) {
+	agentAddon := testinghelpers.NewFakeAgentAddon(deployTestAddonName, []runtime.Object{newAgentConfigMap()}, nil)
+	controller, addonClient, workClient, _ := newDeployController(t, agentAddon, newTestAddon(nil, nil), newTestCluster())
+
+	// another cluster holds the single rollout slot
+	controller.rolloutGate = newRolloutGate(1)
+	controller.rolloutGate.tryAcquire("cluster2")
+
+	if err := syncDeployController(t, controller); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := workClient.WorkV1().ManifestWorks(deployTestClusterName).Get(
+		context.TODO(), constants.DeployWorkName(deployTestAddonName), metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected no work to be applied while the rollout is queued, got %v", err)
+	}
+	testinghelpers.AssertAddonCondition(t, getAddon(t, addonClient).Status.Conditions, metav1.Condition{
+		Type:   RolloutQueuedCondition,
+		Status: metav1.ConditionTrue,
+		Reason: "ConcurrencyLimitReached",
+	})
+}
@@ -0,0 +1,90 @@
+package agentdeploy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/agent"
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ManifestsDegradedCondition is the condition type on the ManagedClusterAddOn reporting that
+// manifests of the deploy works failed to apply, computed under the failure policy of the
+// manager
+const ManifestsDegradedCondition = "ManifestsDegraded"
+
+// updateManifestsDegradedCondition derives the ManifestsDegraded condition from the
+// per-manifest status feedback of the deploy works. Under the default All policy any failed
+// manifest degrades the addon; under BestEffort only manifests the author flagged as critical
+// do. A manifest counts as failed when the work agent reports it not applied or degraded.
+func (c *addonDeployController) updateManifestsDegradedCondition(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+	works, err := c.workLister.ManifestWorks(addon.Namespace).List(
+		labels.SelectorFromSet(labels.Set{constants.AddonWorkLabel: c.addonName, constants.AddonWorkTypeLabel: constants.DeployWorkType}))
+	if err != nil {
+		return err
+	}
+
+	failed := []string{}
+	for _, work := range works {
+		for _, manifest := range work.Status.ResourceStatus.Manifests {
+			if !manifestFailed(manifest) {
+				continue
+			}
+			if c.failurePolicy == agent.FailurePolicyBestEffort && !manifestCritical(work, manifest) {
+				continue
+			}
+			failed = append(failed, fmt.Sprintf("%s/%s", manifest.ResourceMeta.Kind, manifest.ResourceMeta.Name))
+		}
+	}
+	sort.Strings(failed)
+
+	cond := metav1.Condition{
+		Type:    ManifestsDegradedCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  "ManifestsApplied",
+		Message: "All manifests of the deploy works are applied",
+	}
+	if len(failed) > 0 {
+		cond = metav1.Condition{
+			Type:    ManifestsDegradedCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "ManifestApplyFailed",
+			Message: fmt.Sprintf("The manifests %v failed to apply on the managed cluster", failed),
+		}
+	}
+
+	_, _, err = helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond))
+	return err
+}
+
+// manifestFailed reports whether the work agent reported the manifest as failed
+func manifestFailed(manifest workapiv1.ManifestCondition) bool {
+	if applied := meta.FindStatusCondition(manifest.Conditions, string(workapiv1.ManifestApplied)); applied != nil &&
+		applied.Status == metav1.ConditionFalse {
+		return true
+	}
+	return meta.IsStatusConditionTrue(manifest.Conditions, string(workapiv1.ManifestDegraded))
+}
+
+// manifestCritical reports whether the manifest the status feedback refers to carries the
+// critical annotation of the author, located in the work spec via the reported ordinal
+func manifestCritical(work *workapiv1.ManifestWork, manifest workapiv1.ManifestCondition) bool {
+	ordinal := int(manifest.ResourceMeta.Ordinal)
+	if ordinal < 0 || ordinal >= len(work.Spec.Workload.Manifests) {
+		return false
+	}
+	object := &unstructured.Unstructured{}
+	if err := json.Unmarshal(work.Spec.Workload.Manifests[ordinal].Raw, object); err != nil {
+		return false
+	}
+	return object.GetAnnotations()[constants.AddonCriticalManifestAnnotation] == "true"
+}
@@ -0,0 +1,56 @@
+package agentdeploy
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// NewImageOverride returns an override replacing the container images of the workload objects
+// in the agent manifests with the overridden ones. Objects carrying no pod spec are left
+// untouched.
+func NewImageOverride(imageOverrides map[string]string) ManifestOverride {
+	return func(object *unstructured.Unstructured) error {
+		if len(imageOverrides) == 0 {
+			return nil
+		}
+
+		path, ok := podSpecPaths[object.GetKind()]
+		if !ok {
+			return nil
+		}
+
+		for _, field := range []string{"containers", "initContainers"} {
+			containers, found, err := unstructured.NestedSlice(object.Object, append(path, field)...)
+			if err != nil {
+				return err
+			}
+			if !found {
+				continue
+			}
+
+			for _, container := range containers {
+				containerMap, ok := container.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				image, found, err := unstructured.NestedString(containerMap, "image")
+				if err != nil {
+					return err
+				}
+				if !found {
+					continue
+				}
+				if overridden, ok := imageOverrides[image]; ok {
+					if err := unstructured.SetNestedField(containerMap, overridden, "image"); err != nil {
+						return err
+					}
+				}
+			}
+
+			if err := unstructured.SetNestedSlice(object.Object, containers, append(path, field)...); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,141 @@
+package agentdeploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/agent"
+)
+
+// renderIdentifiedManifests renders the agent manifests through AgentManifestsWithIDs and
+// stamps the declared identity of each manifest as an annotation, so the identity travels with
+// the object through the transform and override pipeline. Empty and duplicated identities are
+// a render error.
+func (p *ManifestPipeline) renderIdentifiedManifests(identifiedAgentAddon agent.AgentAddonWithManifestIDs, cluster *clusterv1.ManagedCluster, config runtime.Object) ([]runtime.Object, error) {
+	identified, err := identifiedAgentAddon.AgentManifestsWithIDs(cluster, config)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	objects := []runtime.Object{}
+	for _, manifest := range identified {
+		if len(manifest.ID) == 0 {
+			return nil, fmt.Errorf("AgentManifestsWithIDs returned a manifest with an empty ID")
+		}
+		if seen[manifest.ID] {
+			return nil, fmt.Errorf("AgentManifestsWithIDs returned the manifest ID %q twice", manifest.ID)
+		}
+		seen[manifest.ID] = true
+
+		accessor, err := meta.Accessor(manifest.Object)
+		if err != nil {
+			return nil, err
+		}
+		annotations := accessor.GetAnnotations()
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		annotations[constants.ManifestIDAnnotation] = manifest.ID
+		accessor.SetAnnotations(annotations)
+		objects = append(objects, manifest.Object)
+	}
+	return objects, nil
+}
+
+// manifestIDRecord renders the identity record of the given objects: a JSON map from the
+// declared manifest ID to the coordinates its object is deployed under, persisted on the
+// deploy manifestworks. It is empty when the addon declares no manifest IDs.
+func (c *addonDeployController) manifestIDRecord(cluster *clusterv1.ManagedCluster, objects []runtime.Object) (string, error) {
+	installNamespace := c.agentAddon.GetAgentAddonOptions().InstallNamespace(cluster)
+
+	record := map[string]string{}
+	for _, object := range objects {
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			continue
+		}
+		id, ok := accessor.GetAnnotations()[constants.ManifestIDAnnotation]
+		if !ok {
+			continue
+		}
+		unstructuredObject, err := toUnstructured(object)
+		if err != nil {
+			return "", err
+		}
+		namespace := accessor.GetNamespace()
+		if len(namespace) == 0 {
+			namespace = installNamespace
+		}
+		record[id] = fmt.Sprintf("%s/%s/%s/%s",
+			unstructuredObject.GetAPIVersion(), unstructuredObject.GetKind(), namespace, accessor.GetName())
+	}
+	if len(record) == 0 {
+		return "", nil
+	}
+
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// reportDisappearedManifests compares the identity record of the deployed works with the one
+// of the current render and surfaces every manifest ID that disappeared. The targeted delete
+// itself is issued by dropping the manifest from the deploy work: the work agent garbage
+// collects resources recorded in its appliedmanifestwork once they drop out, so only the
+// resources the addon stopped managing are deleted. A disappeared ID whose coordinates are
+// still claimed by a current manifest changed identity only and is not reported.
+func (c *addonDeployController) reportDisappearedManifests(existingWorks []*workapiv1.ManifestWork, idRecord string) {
+	previous := map[string]string{}
+	for _, work := range existingWorks {
+		raw, ok := work.Annotations[constants.AddonManifestIDsAnnotation]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal([]byte(raw), &previous); err == nil {
+			break
+		}
+	}
+	if len(previous) == 0 {
+		return
+	}
+
+	current := map[string]string{}
+	if len(idRecord) > 0 {
+		// the record was just marshalled, so it unmarshals cleanly
+		_ = json.Unmarshal([]byte(idRecord), &current)
+	}
+	claimed := map[string]bool{}
+	for _, coordinates := range current {
+		claimed[coordinates] = true
+	}
+
+	disappeared := []string{}
+	for id, coordinates := range previous {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		if claimed[coordinates] {
+			// the object is still deployed under a new identity
+			continue
+		}
+		disappeared = append(disappeared, fmt.Sprintf("%s (%s)", id, coordinates))
+	}
+	if len(disappeared) == 0 {
+		return
+	}
+
+	sort.Strings(disappeared)
+	c.eventRecorder.Eventf("AddonManifestsPruned",
+		"Pruning the manifests of addon %q that disappeared from the render: %v", c.addonName, disappeared)
+}
@@ -0,0 +1,58 @@
+package agentdeploy
+
+import (
+	"fmt"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// InvalidManifestScopeError reports an agent manifest whose namespace field is inconsistent
+// with the scope of its resource, which would only fail later with a confusing apply error on
+// the managed cluster.
+type InvalidManifestScopeError struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Reason    string
+}
+
+func (e *InvalidManifestScopeError) Error() string {
+	return fmt.Sprintf("invalid scope of manifest %s %s/%s: %s", e.Kind, e.Namespace, e.Name, e.Reason)
+}
+
+// validateManifestScope checks the namespace of the manifest against the scope of its resource.
+// A namespaced manifest leaving the namespace empty is defaulted to the install namespace of
+// the agent, and a cluster-scoped manifest carrying a namespace is rejected. Kinds unknown to
+// the RESTMapper are left untouched, e.g. a CRD that only exists on the managed cluster.
+func (p *ManifestPipeline) validateManifestScope(cluster *clusterv1.ManagedCluster, object *unstructured.Unstructured) error {
+	if p.restMapper == nil {
+		return nil
+	}
+
+	gvk := object.GroupVersionKind()
+	mapping, err := p.restMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		// the resource is not known on the hub, skip the scope validation
+		return nil
+	}
+
+	switch mapping.Scope.Name() {
+	case meta.RESTScopeNameNamespace:
+		if len(object.GetNamespace()) == 0 {
+			object.SetNamespace(p.agentAddon.GetAgentAddonOptions().InstallNamespace(cluster))
+		}
+	case meta.RESTScopeNameRoot:
+		if len(object.GetNamespace()) != 0 {
+			return &InvalidManifestScopeError{
+				Kind:      object.GetKind(),
+				Namespace: object.GetNamespace(),
+				Name:      object.GetName(),
+				Reason:    "the resource is cluster scoped but the manifest sets a namespace",
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package agentdeploy
+
+import (
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/open-cluster-management/addon-framework/pkg/agent"
+)
+
+// withMonitoringManifests appends the scrape configuration manifests of an addon implementing
+// AgentAddonWithMonitoring to the rendered agent manifests. Each monitoring manifest without a
+// required-claim annotation of its own is made conditional on the monitoring claim, so the
+// claim filter drops it on clusters without a monitoring operator instead of the deploy work
+// failing there.
+func (p *ManifestPipeline) withMonitoringManifests(cluster *clusterv1.ManagedCluster, config runtime.Object, objects []runtime.Object) ([]runtime.Object, error) {
+	monitoringAgentAddon, ok := p.agentAddon.(agent.AgentAddonWithMonitoring)
+	if !ok {
+		return objects, nil
+	}
+
+	monitoringObjects, err := monitoringAgentAddon.AgentMonitoringManifests(cluster, config)
+	if err != nil {
+		return nil, err
+	}
+	for _, object := range monitoringObjects {
+		accessor, err := meta.Accessor(object)
+		if err != nil {
+			continue
+		}
+		if _, ok := accessor.GetAnnotations()[agent.RequiredClaimAnnotation]; ok {
+			// the author declared their own claim requirement; keep it
+			continue
+		}
+		agent.RequireClaim(object, agent.MonitoringClaimName, "")
+	}
+	return append(objects, monitoringObjects...), nil
+}
@@ -0,0 +1,69 @@
+package agentdeploy
+
+import (
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// NewNodePlacementOverride returns an override merging the given nodeSelector and tolerations
+// into the pod template of the workload objects in the agent manifests. Author-set values are
+// kept; the given nodeSelector entries win on conflicting keys and tolerations not present yet
+// are appended. Objects carrying no pod spec are left untouched.
+func NewNodePlacementOverride(nodeSelector map[string]string, tolerations []corev1.Toleration) ManifestOverride {
+	return func(object *unstructured.Unstructured) error {
+		path, ok := podSpecPaths[object.GetKind()]
+		if !ok {
+			return nil
+		}
+		if _, found, err := unstructured.NestedMap(object.Object, path...); err != nil || !found {
+			return err
+		}
+
+		if len(nodeSelector) > 0 {
+			existing, _, err := unstructured.NestedStringMap(object.Object, append(path, "nodeSelector")...)
+			if err != nil {
+				return err
+			}
+			if existing == nil {
+				existing = map[string]string{}
+			}
+			for key, value := range nodeSelector {
+				existing[key] = value
+			}
+			if err := unstructured.SetNestedStringMap(object.Object, existing, append(path, "nodeSelector")...); err != nil {
+				return err
+			}
+		}
+
+		if len(tolerations) > 0 {
+			existing, _, err := unstructured.NestedSlice(object.Object, append(path, "tolerations")...)
+			if err != nil {
+				return err
+			}
+			for _, toleration := range tolerations {
+				content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(toleration.DeepCopy())
+				if err != nil {
+					return err
+				}
+				duplicated := false
+				for _, existingToleration := range existing {
+					if equality.Semantic.DeepEqual(existingToleration, content) {
+						duplicated = true
+						break
+					}
+				}
+				if !duplicated {
+					existing = append(existing, content)
+				}
+			}
+			if err := unstructured.SetNestedSlice(object.Object, existing, append(path, "tolerations")...); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+package agentdeploy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// waveRequeueDelay is the delay before the deploy is reconciled again while waiting for the
+// work of the previous wave to become available
+const waveRequeueDelay = 30 * time.Second
+
+// manifestWave returns the deploy wave of the given manifest. The wave annotation wins if it
+// is set; otherwise CRDs come first, then namespaces, then everything else, which resolves the
+// common CRD-before-CR and namespace-before-namespaced races.
+func manifestWave(object *unstructured.Unstructured) (int, error) {
+	if value, ok := object.GetAnnotations()[constants.AddonDeployWaveAnnotation]; ok {
+		wave, err := strconv.Atoi(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid deploy wave %q on manifest %s/%s: %v", value, object.GetNamespace(), object.GetName(), err)
+		}
+		return wave, nil
+	}
+
+	switch object.GetKind() {
+	case "CustomResourceDefinition":
+		return 0, nil
+	case "Namespace":
+		return 1, nil
+	default:
+		return 2, nil
+	}
+}
+
+// applyOrderedWorks splits the agent manifests into one manifestwork per deploy wave and
+// applies the waves in order. The work of a wave is only applied once the work of the previous
+// wave reports the Available condition; otherwise the deploy is requeued to check again later.
+// Once every wave is applied, deploy works of waves that no longer exist are pruned, so a wave
+// emptied by a render change does not keep its manifests deployed.
+func (c *addonDeployController) applyOrderedWorks(ctx context.Context, syncCtx factory.SyncContext, clusterName string, manifests []agentManifest, digest, fingerprint, idRecord, recreateDigest string) error {
+	manifestsByWave := map[int][]agentManifest{}
+	for _, manifest := range manifests {
+		manifestsByWave[manifest.wave] = append(manifestsByWave[manifest.wave], manifest)
+	}
+
+	waves := []int{}
+	for wave := range manifestsByWave {
+		waves = append(waves, wave)
+	}
+	sort.Ints(waves)
+
+	// every wave work records the full set of wave work names, so a single wave work deleted
+	// out-of-band shows up as a missing sibling in the render input short-circuit
+	names := []string{}
+	for _, wave := range waves {
+		names = append(names, constants.DeployWaveWorkName(c.addonName, wave))
+	}
+	expectedWorks := strings.Join(names, ",")
+
+	for index, wave := range waves {
+		if index > 0 {
+			available, err := c.workAvailable(clusterName, constants.DeployWaveWorkName(c.addonName, waves[index-1]))
+			if err != nil {
+				return err
+			}
+			if !available {
+				syncCtx.Queue().AddAfter(syncCtx.QueueKey(), waveRequeueDelay)
+				return nil
+			}
+		}
+
+		work := c.newDeployWork(constants.DeployWaveWorkName(c.addonName, wave), clusterName, manifestsByWave[wave], digest, fingerprint, idRecord, recreateDigest)
+		work.Annotations[constants.AddonDeployWorksAnnotation] = expectedWorks
+		if err := c.applyWork(ctx, work); err != nil {
+			return err
+		}
+	}
+
+	keep := sets.NewString()
+	for _, wave := range waves {
+		keep.Insert(constants.DeployWaveWorkName(c.addonName, wave))
+	}
+	return c.pruneObsoleteDeployWorks(ctx, clusterName, keep)
+}
+
+// workAvailable returns true if the given manifestwork reports the Available condition
+func (c *addonDeployController) workAvailable(clusterName, workName string) (bool, error) {
+	work, err := c.workLister.ManifestWorks(clusterName).Get(workName)
+	switch {
+	case errors.IsNotFound(err):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+
+	return meta.IsStatusConditionTrue(work.Status.Conditions, workapiv1.WorkAvailable), nil
+}
@@ -0,0 +1,18 @@
+package agentdeploy
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ManifestOverride mutates a rendered agent manifest before it is packaged into the deploy
+// manifestwork. Overrides are applied in the order they are registered on the AddonManager.
+type ManifestOverride func(object *unstructured.Unstructured) error
+
+// podSpecPaths maps a workload kind to the field path of its pod spec
+var podSpecPaths = map[string][]string{
+	"Pod":         {"spec"},
+	"Deployment":  {"spec", "template", "spec"},
+	"DaemonSet":   {"spec", "template", "spec"},
+	"StatefulSet": {"spec", "template", "spec"},
+	"Job":         {"spec", "template", "spec"},
+}
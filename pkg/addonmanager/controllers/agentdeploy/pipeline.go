@@ -0,0 +1,186 @@
+package agentdeploy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/open-cluster-management/addon-framework/pkg/agent"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ManifestPipeline turns the rendered objects of an agent addon into the work manifests the
+// deploy controller ships to the managed cluster: rendering, the registered transformers and
+// overrides, the namespace, service account, pod disruption budget, hub CA and pull secret
+// decorations, and the scope validation. The deploy controller embeds a pipeline for its
+// syncs; Render exposes the same pipeline to offline consumers such as the render subcommand,
+// so a dumped manifest matches what the controller would deploy.
+type ManifestPipeline struct {
+	addonName          string
+	agentAddon         agent.AgentAddon
+	restMapper         meta.RESTMapper
+	overrides          []ManifestOverride
+	transformers       []ManifestTransformer
+	createNamespace    bool
+	namespaceLabels    map[string]string
+	serviceAccountName string
+	serviceAccountNS   string
+	minAvailable       string
+	pullSecretName     string
+	pullSecretData     []byte
+	hubCABundle        []byte
+	hubCAConfigMapName string
+	provenance         bool
+}
+
+// NewManifestPipeline returns a pipeline producing the agent manifests of the addon. The rest
+// mapper is optional; without it the manifest scope validation is skipped.
+func NewManifestPipeline(
+	addonName string,
+	agentAddon agent.AgentAddon,
+	restMapper meta.RESTMapper,
+	overrides []ManifestOverride,
+	transformers []ManifestTransformer,
+	createNamespace bool,
+	namespaceLabels map[string]string,
+	serviceAccountName string,
+	serviceAccountNS string,
+	minAvailable string,
+	pullSecretName string,
+	pullSecretData []byte,
+	hubCABundle []byte,
+	hubCAConfigMapName string,
+	provenance bool,
+) *ManifestPipeline {
+	return &ManifestPipeline{
+		addonName:          addonName,
+		agentAddon:         agentAddon,
+		restMapper:         restMapper,
+		overrides:          overrides,
+		transformers:       transformers,
+		createNamespace:    createNamespace,
+		namespaceLabels:    namespaceLabels,
+		serviceAccountName: serviceAccountName,
+		serviceAccountNS:   serviceAccountNS,
+		minAvailable:       minAvailable,
+		pullSecretName:     pullSecretName,
+		pullSecretData:     pullSecretData,
+		hubCABundle:        hubCABundle,
+		hubCAConfigMapName: hubCAConfigMapName,
+		provenance:         provenance,
+	}
+}
+
+// Render runs the whole pipeline on the addon for the given cluster and returns the work
+// manifests in deploy order. The optional render inputs carry their zero values; a caller with
+// access to the hub, like the deploy controller, runs the steps itself with gathered inputs.
+func (p *ManifestPipeline) Render(cluster *clusterv1.ManagedCluster, config runtime.Object) ([]workapiv1.Manifest, error) {
+	objects, err := p.renderManifests(cluster, config, agent.ManifestInputs{})
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err = p.transform(cluster, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	objects = filterByRequiredClaims(cluster, objects)
+	if len(objects) == 0 {
+		return nil, nil
+	}
+
+	objects = p.withInstallNamespace(cluster, objects)
+	objects = p.withAgentServiceAccount(cluster, objects)
+	objects = p.withPodDisruptionBudgets(cluster, objects)
+	objects = p.withHubCAConfigMap(cluster, objects)
+	objects = p.withImagePullSecret(cluster, objects)
+
+	manifests, err := p.buildAgentManifests(cluster, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	workManifests := []workapiv1.Manifest{}
+	for _, manifest := range manifests {
+		workManifests = append(workManifests, manifest.manifest)
+	}
+	return workManifests, nil
+}
+
+// renderManifests invokes the manifest rendering of the agent addon with the given optional
+// render inputs and appends the monitoring manifests.
+func (p *ManifestPipeline) renderManifests(cluster *clusterv1.ManagedCluster, config runtime.Object, inputs agent.ManifestInputs) ([]runtime.Object, error) {
+	objects, err := p.renderAgentManifests(cluster, config, inputs)
+	if err != nil {
+		return nil, err
+	}
+	return p.withMonitoringManifests(cluster, config, objects)
+}
+
+// renderAgentManifests renders the agent manifests through the render interface the addon
+// implements. An addon needing several of the optional render inputs implements
+// agent.AgentAddonWithManifestInputs and receives all of them in one call; implementing more
+// than one of the single-input interfaces is rejected as ambiguous instead of one of them
+// silently shadowing the others.
+func (p *ManifestPipeline) renderAgentManifests(cluster *clusterv1.ManagedCluster, config runtime.Object, inputs agent.ManifestInputs) ([]runtime.Object, error) {
+	if composedAgentAddon, ok := p.agentAddon.(agent.AgentAddonWithManifestInputs); ok {
+		return composedAgentAddon.AgentManifestsWithInputs(cluster, config, inputs)
+	}
+
+	names := []string{}
+	renders := []func() ([]runtime.Object, error){}
+	if connectivityAgentAddon, ok := p.agentAddon.(agent.AgentAddonWithConnectivity); ok {
+		names = append(names, "AgentAddonWithConnectivity")
+		renders = append(renders, func() ([]runtime.Object, error) {
+			return connectivityAgentAddon.AgentManifestsWithConnectivity(cluster, config, inputs.Connectivity)
+		})
+	}
+	if identifiedAgentAddon, ok := p.agentAddon.(agent.AgentAddonWithManifestIDs); ok {
+		names = append(names, "AgentAddonWithManifestIDs")
+		renders = append(renders, func() ([]runtime.Object, error) {
+			return p.renderIdentifiedManifests(identifiedAgentAddon, cluster, config)
+		})
+	}
+	if secretAwareAgentAddon, ok := p.agentAddon.(agent.AgentAddonWithHubSecrets); ok {
+		names = append(names, "AgentAddonWithHubSecrets")
+		renders = append(renders, func() ([]runtime.Object, error) {
+			return secretAwareAgentAddon.AgentManifestsWithHubSecrets(cluster, config, inputs.HubSecrets)
+		})
+	}
+	if installedAwareAgentAddon, ok := p.agentAddon.(agent.AgentAddonWithInstalledAddons); ok {
+		names = append(names, "AgentAddonWithInstalledAddons")
+		renders = append(renders, func() ([]runtime.Object, error) {
+			return installedAwareAgentAddon.AgentManifestsWithInstalledAddons(cluster, config, inputs.InstalledAddons)
+		})
+	}
+	if digestAgentAddon, ok := p.agentAddon.(agent.AgentAddonWithDeployedDigest); ok {
+		names = append(names, "AgentAddonWithDeployedDigest")
+		renders = append(renders, func() ([]runtime.Object, error) {
+			return digestAgentAddon.AgentManifestsWithDeployedDigest(cluster, config, inputs.DeployedDigest)
+		})
+	}
+
+	switch len(renders) {
+	case 0:
+		return p.agentAddon.AgentManifests(cluster, config)
+	case 1:
+		return renders[0]()
+	default:
+		return nil, fmt.Errorf("the agent addon implements %s, whose render inputs would shadow each other; implement AgentAddonWithManifestInputs to combine them", strings.Join(names, " and "))
+	}
+}
+
+// transform runs the registered transformers on the rendered objects in registration order
+func (p *ManifestPipeline) transform(cluster *clusterv1.ManagedCluster, objects []runtime.Object) ([]runtime.Object, error) {
+	var err error
+	for _, transform := range p.transformers {
+		objects, err = transform(cluster, objects)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objects, nil
+}
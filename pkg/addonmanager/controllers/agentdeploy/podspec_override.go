@@ -0,0 +1,38 @@
+package agentdeploy
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// NewPodSpecOverride returns an override applying the given function to the pod spec of every
+// workload object in the agent manifests, e.g. to inject a CA-bundle volume or proxy env vars
+// into the agent containers. The pod spec is converted to its typed form for the function and
+// written back afterwards, so the function can use plain corev1 mutations instead of
+// unstructured traversal. Objects carrying no pod spec are left untouched.
+func NewPodSpecOverride(override func(podSpec *corev1.PodSpec)) ManifestOverride {
+	return func(object *unstructured.Unstructured) error {
+		path, ok := podSpecPaths[object.GetKind()]
+		if !ok {
+			return nil
+		}
+		content, found, err := unstructured.NestedMap(object.Object, path...)
+		if err != nil || !found {
+			return err
+		}
+
+		podSpec := &corev1.PodSpec{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(content, podSpec); err != nil {
+			return err
+		}
+
+		override(podSpec)
+
+		updated, err := runtime.DefaultUnstructuredConverter.ToUnstructured(podSpec)
+		if err != nil {
+			return err
+		}
+		return unstructured.SetNestedMap(object.Object, updated, path...)
+	}
+}
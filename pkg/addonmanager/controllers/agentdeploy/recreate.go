@@ -0,0 +1,114 @@
+package agentdeploy
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/agent"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog/v2"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+)
+
+// recreateRequeueDelay is how soon the deploy is requeued after shipping the deletion phase of
+// a recreate upgrade, giving the work agent time to prune the removed manifests
+const recreateRequeueDelay = 5 * time.Second
+
+// withRecreatePhase implements the Recreate upgrade strategy as two sequential versions of the
+// deploy works. When a manifest the author flagged for recreation changed, the works are first
+// applied without the changed manifests so the work agent deletes them from the managed
+// cluster, and the full new version is applied on a follow-up reconcile. It returns the
+// manifests to apply now together with the digest, fingerprint and recreate marker to stamp on
+// the works.
+func (c *addonDeployController) withRecreatePhase(syncCtx factory.SyncContext, clusterName string, manifests []agentManifest, digest, fingerprint string, existingWorks []*workapiv1.ManifestWork) ([]agentManifest, string, string, string) {
+	if c.upgradeStrategy != agent.UpgradeStrategyRecreate || len(existingWorks) == 0 {
+		return manifests, digest, fingerprint, ""
+	}
+
+	previousDigest := ""
+	recreateDigest := ""
+	existingRaw := sets.NewString()
+	existingCoordinates := sets.NewString()
+	for _, work := range existingWorks {
+		if value := work.Annotations[constants.AddonManifestDigestAnnotation]; len(value) > 0 {
+			previousDigest = value
+		}
+		if value := work.Annotations[constants.AddonRecreateDigestAnnotation]; len(value) > 0 {
+			recreateDigest = value
+		}
+		for _, manifest := range work.Spec.Workload.Manifests {
+			existingRaw.Insert(string(manifest.Raw))
+			if coordinates, err := manifestCoordinates(manifest.Raw); err == nil {
+				existingCoordinates.Insert(coordinates)
+			}
+		}
+	}
+
+	if len(previousDigest) == 0 || previousDigest == digest {
+		// a fresh install or an unchanged deploy; nothing to recreate
+		return manifests, digest, fingerprint, ""
+	}
+	if recreateDigest == digest {
+		// the deletion phase of this version was shipped already; apply the full version
+		return manifests, digest, fingerprint, ""
+	}
+
+	kept := []agentManifest{}
+	removed := 0
+	for _, manifest := range manifests {
+		if c.needsRecreate(manifest, existingRaw, existingCoordinates) {
+			removed++
+			continue
+		}
+		kept = append(kept, manifest)
+	}
+	if removed == 0 {
+		return manifests, digest, fingerprint, ""
+	}
+
+	// ship the deletion phase: the changed flagged manifests are withheld so the work agent
+	// prunes them, and the recreate marker records which version the deletion belongs to. The
+	// fingerprint is replaced with a marker so the follow-up reconcile does not short-circuit
+	// on unchanged render inputs.
+	klog.V(4).Infof("Recreating %d changed agent manifests of addon %q on cluster %q", removed, c.addonName, clusterName)
+	c.eventRecorder.Eventf("AddonAgentRecreating",
+		"Deleting %d changed agent manifests of addon %q on cluster %q before applying their new version", removed, c.addonName, clusterName)
+	syncCtx.Queue().AddAfter(clusterName+"/"+c.addonName, recreateRequeueDelay)
+	return kept, manifestsDigest(kept), "recreate-pending:" + digest, digest
+}
+
+// needsRecreate reports whether the manifest is flagged for recreation and changed against the
+// version currently deployed. A flagged manifest whose coordinates never existed on the
+// cluster is a plain create and needs no deletion phase.
+func (c *addonDeployController) needsRecreate(manifest agentManifest, existingRaw, existingCoordinates sets.String) bool {
+	object := &unstructured.Unstructured{}
+	if err := json.Unmarshal(manifest.manifest.Raw, object); err != nil {
+		return false
+	}
+	if object.GetAnnotations()[constants.AddonRecreateOnChangeAnnotation] != "true" {
+		return false
+	}
+	if existingRaw.Has(string(manifest.manifest.Raw)) {
+		// unchanged
+		return false
+	}
+	coordinates, err := manifestCoordinates(manifest.manifest.Raw)
+	if err != nil {
+		return false
+	}
+	return existingCoordinates.Has(coordinates)
+}
+
+// manifestCoordinates renders the identifying coordinates of a raw manifest
+func manifestCoordinates(raw []byte) (string, error) {
+	object := &unstructured.Unstructured{}
+	if err := json.Unmarshal(raw, object); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s/%s/%s/%s", object.GetAPIVersion(), object.GetKind(), object.GetNamespace(), object.GetName()), nil
+}
@@ -0,0 +1,83 @@
+package agentdeploy
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/agent"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// renderFingerprint returns the fingerprint of the declared render inputs of the agent addon
+// for the given cluster and config, or an empty string if the addon declares no inputs. The
+// resource version of the config is part of the fingerprint so a config change always forces
+// a re-render.
+func (c *addonDeployController) renderFingerprint(cluster *clusterv1.ManagedCluster, config runtime.Object) string {
+	inputsAgentAddon, ok := c.agentAddon.(agent.AgentAddonWithRenderInputs)
+	if !ok {
+		return ""
+	}
+	inputs := inputsAgentAddon.RenderInputs()
+
+	hash := sha256.New()
+	for _, key := range inputs.Labels {
+		fmt.Fprintf(hash, "label/%s=%s\n", key, cluster.Labels[key])
+	}
+	for _, name := range inputs.Claims {
+		value := ""
+		for _, claim := range cluster.Status.ClusterClaims {
+			if claim.Name == name {
+				value = claim.Value
+			}
+		}
+		fmt.Fprintf(hash, "claim/%s=%s\n", name, value)
+	}
+	if config != nil {
+		if accessor, err := meta.Accessor(config); err == nil {
+			fmt.Fprintf(hash, "config/%s\n", accessor.GetResourceVersion())
+		}
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+// renderInputsUnchanged returns whether the existing deploy works of the addon were rendered
+// from the same render input fingerprint, in which case the re-render can be skipped. The
+// short-circuit is defeated when a deploy work named by the sibling record of another work is
+// missing, so a wave work deleted out-of-band is recreated instead of staying gone until a
+// declared input happens to change.
+func (c *addonDeployController) renderInputsUnchanged(clusterName, fingerprint string) (bool, error) {
+	if len(fingerprint) == 0 {
+		return false, nil
+	}
+
+	works, err := c.workLister.ManifestWorks(clusterName).List(
+		labels.SelectorFromSet(labels.Set{constants.AddonWorkLabel: c.addonName, constants.AddonWorkTypeLabel: constants.DeployWorkType}))
+	if err != nil {
+		return false, err
+	}
+	if len(works) == 0 {
+		return false, nil
+	}
+
+	expected := sets.NewString()
+	existing := sets.NewString()
+	for _, work := range works {
+		if work.Annotations[constants.AddonRenderInputsAnnotation] != fingerprint {
+			return false, nil
+		}
+		existing.Insert(work.Name)
+		if value := work.Annotations[constants.AddonDeployWorksAnnotation]; len(value) > 0 {
+			expected.Insert(strings.Split(value, ",")...)
+		}
+	}
+	if expected.Difference(existing).Len() > 0 {
+		return false, nil
+	}
+	return true, nil
+}
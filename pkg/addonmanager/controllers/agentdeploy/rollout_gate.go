@@ -0,0 +1,114 @@
+package agentdeploy
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+// RolloutQueuedCondition is the condition type on the ManagedClusterAddOn reporting that a
+// pending change of the addon is held back by the rollout concurrency limit of the manager
+// until earlier clusters finish their rollout
+const RolloutQueuedCondition = "RolloutQueued"
+
+// rolloutRequeueDelay is how soon a queued cluster is retried for a free rollout slot
+const rolloutRequeueDelay = 30 * time.Second
+
+// rolloutGate bounds how many clusters roll out a pending change simultaneously, so a bad
+// manifest is caught on the first few clusters before it hits the whole fleet. It is shared
+// across the per-cluster syncs of the deploy controller.
+type rolloutGate struct {
+	mu     sync.Mutex
+	limit  int
+	active sets.String
+}
+
+// newRolloutGate returns a gate admitting up to limit concurrent rollouts, or nil for an
+// unlimited rollout
+func newRolloutGate(limit int) *rolloutGate {
+	if limit <= 0 {
+		return nil
+	}
+	return &rolloutGate{limit: limit, active: sets.NewString()}
+}
+
+// tryAcquire grants the cluster a rollout slot, reporting false when the limit is reached. A
+// cluster already holding a slot is admitted again, so a retried rollout never queues behind
+// itself.
+func (g *rolloutGate) tryAcquire(clusterName string) bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.active.Has(clusterName) {
+		return true
+	}
+	if g.active.Len() >= g.limit {
+		return false
+	}
+	g.active.Insert(clusterName)
+	return true
+}
+
+// release frees the rollout slot of the cluster
+func (g *rolloutGate) release(clusterName string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.active.Delete(clusterName)
+}
+
+// gateRollout admits or queues a pending change of the cluster under the rollout concurrency
+// limit. A cluster whose deployed digest already matches the desired one has no pending
+// change; its slot is released once the addon reports available, making room for the next
+// queued cluster. It returns whether the sync may proceed to apply the works.
+func (c *addonDeployController) gateRollout(ctx context.Context, syncCtx factory.SyncContext, addon *addonapiv1alpha1.ManagedClusterAddOn, digest string, existingWorks []*workapiv1.ManifestWork) (bool, error) {
+	if c.rolloutGate == nil {
+		return true, nil
+	}
+
+	previousDigest := ""
+	for _, work := range existingWorks {
+		if value := work.Annotations[constants.AddonManifestDigestAnnotation]; len(value) > 0 {
+			previousDigest = value
+		}
+	}
+
+	if previousDigest == digest {
+		// the Available condition is maintained by the lease controller of the spoke agent
+		if meta.IsStatusConditionTrue(addon.Status.Conditions, "Available") {
+			c.rolloutGate.release(addon.Namespace)
+		}
+		return true, nil
+	}
+
+	if !c.rolloutGate.tryAcquire(addon.Namespace) {
+		cond := metav1.Condition{
+			Type:    RolloutQueuedCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "ConcurrencyLimitReached",
+			Message: fmt.Sprintf("The rollout to cluster %q is queued behind %d clusters by the rollout concurrency limit", addon.Namespace, c.rolloutGate.limit),
+		}
+		if _, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond)); err != nil {
+			return false, err
+		}
+		syncCtx.Queue().AddAfter(syncCtx.QueueKey(), rolloutRequeueDelay)
+		return false, nil
+	}
+
+	cond := metav1.Condition{
+		Type:    RolloutQueuedCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  "RolloutProceeding",
+		Message: "The rollout to this cluster holds a rollout slot",
+	}
+	_, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond))
+	return true, err
+}
@@ -0,0 +1,54 @@
+package agentdeploy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ManifestTransformed is the condition type reporting whether the registered manifest
+// transformers succeeded on the rendered agent manifests.
+const ManifestTransformed = "ManifestTransformed"
+
+// ManifestTransformer mutates the whole set of rendered agent manifests of a managed cluster
+// before they are packaged into the deploy manifestwork. Unlike a ManifestOverride, which
+// rewrites one object at a time, a transformer may add, drop or reorder objects, e.g. to
+// inject a sidecar or append a network policy. Transformers compose in registration order.
+type ManifestTransformer func(cluster *clusterv1.ManagedCluster, objects []runtime.Object) ([]runtime.Object, error)
+
+// transformManifests runs the registered transformers on the rendered objects and reports the
+// outcome as a condition on the addon, so an author-provided transformer failure is visible on
+// the addon status instead of only in the controller log.
+func (c *addonDeployController) transformManifests(ctx context.Context, cluster *clusterv1.ManagedCluster, addon *addonapiv1alpha1.ManagedClusterAddOn, objects []runtime.Object) ([]runtime.Object, error) {
+	if len(c.transformers) == 0 {
+		return objects, nil
+	}
+
+	objects, transformErr := c.transform(cluster, objects)
+
+	cond := metav1.Condition{
+		Type:    ManifestTransformed,
+		Status:  metav1.ConditionTrue,
+		Reason:  "TransformersSucceeded",
+		Message: "All manifest transformers succeeded",
+	}
+	if transformErr != nil {
+		cond = metav1.Condition{
+			Type:    ManifestTransformed,
+			Status:  metav1.ConditionFalse,
+			Reason:  "TransformerFailed",
+			Message: fmt.Sprintf("A manifest transformer failed: %v", transformErr),
+		}
+	}
+
+	if _, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond)); err != nil {
+		return nil, err
+	}
+
+	return objects, transformErr
+}
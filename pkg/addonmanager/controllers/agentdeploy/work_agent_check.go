@@ -0,0 +1,72 @@
+package agentdeploy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// WorkAgentUnresponsiveCondition is the condition type set on the ManagedClusterAddOn when a
+// deploy manifestwork has existed for longer than the grace period without any Applied status,
+// which indicates the work agent on the managed cluster is not running or broken.
+const WorkAgentUnresponsiveCondition = "WorkAgentUnresponsive"
+
+// DefaultWorkAgentGracePeriod is the default wait before an unpicked deploy work is reported
+// as a work agent problem
+const DefaultWorkAgentGracePeriod = 2 * time.Minute
+
+// updateWorkAgentCondition reports on the addon whether the work agent of the managed cluster
+// has picked up the deploy works, distinguishing a broken work agent from failing manifests.
+// The deploy is requeued while a work is still within the grace period.
+func (c *addonDeployController) updateWorkAgentCondition(ctx context.Context, syncCtx factory.SyncContext, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+	works, err := c.workLister.ManifestWorks(addon.Namespace).List(
+		labels.SelectorFromSet(labels.Set{constants.AddonWorkLabel: c.addonName, constants.AddonWorkTypeLabel: constants.DeployWorkType}))
+	if err != nil {
+		return err
+	}
+
+	unresponsive := []string{}
+	waiting := false
+	for _, work := range works {
+		if meta.FindStatusCondition(work.Status.Conditions, workapiv1.WorkApplied) != nil {
+			continue
+		}
+		if time.Since(work.CreationTimestamp.Time) > c.workAgentGracePeriod {
+			unresponsive = append(unresponsive, work.Name)
+			continue
+		}
+		waiting = true
+	}
+
+	if waiting {
+		// check again once the grace period of the pending works could have expired
+		syncCtx.Queue().AddAfter(syncCtx.QueueKey(), c.workAgentGracePeriod)
+	}
+
+	cond := metav1.Condition{
+		Type:    WorkAgentUnresponsiveCondition,
+		Status:  metav1.ConditionFalse,
+		Reason:  "WorkApplied",
+		Message: "The work agent has picked up the deploy works",
+	}
+	if len(unresponsive) > 0 {
+		cond = metav1.Condition{
+			Type:    WorkAgentUnresponsiveCondition,
+			Status:  metav1.ConditionTrue,
+			Reason:  "NoWorkApplied",
+			Message: fmt.Sprintf("The work agent has not picked up the deploy works %v; the work agent may not be running on the managed cluster", unresponsive),
+		}
+	}
+
+	_, _, err = helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond))
+	return err
+}
@@ -0,0 +1,70 @@
+package agentdeploy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultMaxManifestWorkSize is the default limit on the serialized size of a deploy
+// manifestwork. It stays well below the request size the api server accepts, so an oversized
+// work fails with a clear diagnostic instead of an opaque apiserver rejection.
+const DefaultMaxManifestWorkSize = 500 * 1024
+
+// OversizedManifestWork is the condition type reporting that a deploy manifestwork exceeds the
+// configured size limit.
+const OversizedManifestWork = "OversizedManifestWork"
+
+// OversizedManifestWorkError reports a manifestwork whose serialized size exceeds the
+// configured limit
+type OversizedManifestWorkError struct {
+	WorkName string
+	Size     int
+	Limit    int
+}
+
+func (e *OversizedManifestWorkError) Error() string {
+	return fmt.Sprintf("manifestwork %s is %d bytes, exceeding the %d bytes limit", e.WorkName, e.Size, e.Limit)
+}
+
+// checkWorkSize returns an OversizedManifestWorkError if the serialized size of the work
+// exceeds the configured limit
+func (c *addonDeployController) checkWorkSize(work *workapiv1.ManifestWork) error {
+	raw, err := json.Marshal(work)
+	if err != nil {
+		return err
+	}
+	if len(raw) > c.maxWorkSize {
+		return &OversizedManifestWorkError{WorkName: work.Name, Size: len(raw), Limit: c.maxWorkSize}
+	}
+	return nil
+}
+
+// updateOversizedCondition reports on the addon whether a deploy manifestwork exceeds the size
+// limit, with the actual size in the message so authors know how far over they are
+func (c *addonDeployController) updateOversizedCondition(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn, applyErr error) error {
+	cond := metav1.Condition{
+		Type:    OversizedManifestWork,
+		Status:  metav1.ConditionFalse,
+		Reason:  "ManifestWorkSized",
+		Message: "The deploy manifestworks are within the size limit",
+	}
+	var oversizedErr *OversizedManifestWorkError
+	if errors.As(applyErr, &oversizedErr) {
+		cond = metav1.Condition{
+			Type:    OversizedManifestWork,
+			Status:  metav1.ConditionTrue,
+			Reason:  "ManifestWorkOversized",
+			Message: fmt.Sprintf("The deploy manifestwork %s is %d bytes, exceeding the %d bytes limit", oversizedErr.WorkName, oversizedErr.Size, oversizedErr.Limit),
+		}
+	}
+
+	_, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond))
+	return err
+}
@@ -0,0 +1,105 @@
+package cleanup
+
+import (
+	"context"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/controllers/addonmetrics"
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addoninformerv1alpha1 "github.com/open-cluster-management/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "github.com/open-cluster-management/api/client/addon/listers/addon/v1alpha1"
+	workv1client "github.com/open-cluster-management/api/client/work/clientset/versioned"
+	workinformersv1 "github.com/open-cluster-management/api/client/work/informers/externalversions/work/v1"
+	worklisterv1 "github.com/open-cluster-management/api/client/work/listers/work/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+)
+
+// orphanWorkController removes the manifestworks carrying the addon work label whose addon no
+// longer exists, e.g. works left behind after the addon name of a manager changed or a crashed
+// manager never cleaned up. It is only started when the orphan cleanup is explicitly enabled on
+// the AddonManager.
+type orphanWorkController struct {
+	workClient        workv1client.Interface
+	deletePropagation metav1.DeletionPropagation
+	addonLister       addonlisterv1alpha1.ManagedClusterAddOnLister
+	workLister        worklisterv1.ManifestWorkLister
+	eventRecorder     events.Recorder
+}
+
+// NewOrphanWorkController returns a controller removing the orphaned addon deploy manifestworks
+func NewOrphanWorkController(
+	workClient workv1client.Interface,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	workInformers workinformersv1.ManifestWorkInformer,
+	deletePropagation metav1.DeletionPropagation,
+	recorder events.Recorder,
+) factory.Controller {
+	c := &orphanWorkController{
+		workClient:        workClient,
+		deletePropagation: deletePropagation,
+		addonLister:       addonInformers.Lister(),
+		workLister:        workInformers.Lister(),
+		eventRecorder:     recorder.WithComponentSuffix("orphan-work-controller"),
+	}
+
+	return factory.New().
+		WithInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				accessor, _ := meta.Accessor(obj)
+				return accessor.GetNamespace() + "/" + accessor.GetName()
+			},
+			workInformers.Informer()).
+		WithSync(addonmetrics.InstrumentSync("OrphanWorkController", c.sync)).
+		ToController("AddonOrphanWorkController", recorder)
+}
+
+func (c *orphanWorkController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	key := syncCtx.QueueKey()
+	klog.V(4).Infof("Reconciling orphaned addon work %q", key)
+
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore work whose key is not in format: namespace/name
+		return nil
+	}
+
+	work, err := c.workLister.ManifestWorks(namespace).Get(name)
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	addonName, ok := work.Labels[constants.AddonWorkLabel]
+	if !ok {
+		return nil
+	}
+
+	// the work is not orphaned as long as the labeled addon still exists in its namespace
+	_, err = c.addonLister.ManagedClusterAddOns(namespace).Get(addonName)
+	switch {
+	case err == nil:
+		return nil
+	case !errors.IsNotFound(err):
+		return err
+	}
+
+	err = c.workClient.WorkV1().ManifestWorks(namespace).Delete(ctx, name, helpers.DeleteOptionsWithPropagation(c.deletePropagation))
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	c.eventRecorder.Eventf("AddonOrphanWorkDeleted", "Deleted orphaned manifestwork %s/%s of addon %q", namespace, name, addonName)
+	return nil
+}
@@ -0,0 +1,144 @@
+package clusterevents
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/controllers/addonmetrics"
+	addonlisterv1alpha1 "github.com/open-cluster-management/api/client/addon/listers/addon/v1alpha1"
+	clusterlisterv1 "github.com/open-cluster-management/api/client/cluster/listers/cluster/v1"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog/v2"
+
+	addoninformerv1alpha1 "github.com/open-cluster-management/api/client/addon/informers/externalversions/addon/v1alpha1"
+	clusterinformersv1 "github.com/open-cluster-management/api/client/cluster/informers/externalversions/cluster/v1"
+)
+
+// availableConditionType is the condition type on the ManagedClusterAddOn reporting whether
+// the addon agent is available on the managed cluster
+const availableConditionType = "Available"
+
+// clusterEventsController mirrors availability transitions of the addon as kubernetes events
+// referencing the ManagedCluster, so SREs triaging at the cluster level catch addon
+// degradation without watching every ManagedClusterAddOn.
+type clusterEventsController struct {
+	addonName     string
+	kubeClient    kubernetes.Interface
+	addonLister   addonlisterv1alpha1.ManagedClusterAddOnLister
+	clusterLister clusterlisterv1.ManagedClusterLister
+	// lastAvailability tracks the last observed Available status per cluster so an event only
+	// fires on a transition. It is only touched from sync, which the factory controller never
+	// runs concurrently; the first observation after a restart records without an event.
+	lastAvailability map[string]metav1.ConditionStatus
+}
+
+// NewClusterEventsController returns a controller emitting an event on the ManagedCluster
+// whenever the Available condition of the addon transitions
+func NewClusterEventsController(
+	addonName string,
+	kubeClient kubernetes.Interface,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	clusterInformers clusterinformersv1.ManagedClusterInformer,
+	recorder events.Recorder,
+) factory.Controller {
+	c := &clusterEventsController{
+		addonName:        addonName,
+		kubeClient:       kubeClient,
+		addonLister:      addonInformers.Lister(),
+		clusterLister:    clusterInformers.Lister(),
+		lastAvailability: map[string]metav1.ConditionStatus{},
+	}
+
+	return factory.New().
+		WithInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				accessor, _ := apimeta.Accessor(obj)
+				return accessor.GetNamespace() + "/" + accessor.GetName()
+			},
+			addonInformers.Informer()).
+		WithSync(addonmetrics.InstrumentSync("ClusterEventsController", c.sync)).
+		ToController("AddonClusterEventsController", recorder)
+}
+
+func (c *clusterEventsController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	key := syncCtx.QueueKey()
+	klog.V(4).Infof("Reconciling addon cluster events %q", key)
+
+	clusterName, addonName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore addon whose key is not in format: namespace/name
+		return nil
+	}
+	if addonName != c.addonName {
+		return nil
+	}
+
+	addon, err := c.addonLister.ManagedClusterAddOns(clusterName).Get(addonName)
+	switch {
+	case errors.IsNotFound(err):
+		delete(c.lastAvailability, clusterName)
+		return nil
+	case err != nil:
+		return err
+	}
+
+	availability := metav1.ConditionUnknown
+	if cond := apimeta.FindStatusCondition(addon.Status.Conditions, availableConditionType); cond != nil {
+		availability = cond.Status
+	}
+
+	previous, observed := c.lastAvailability[clusterName]
+	c.lastAvailability[clusterName] = availability
+	if !observed || previous == availability {
+		// not a transition; stay silent to avoid event spam
+		return nil
+	}
+
+	cluster, err := c.clusterLister.Get(clusterName)
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	eventType := corev1.EventTypeNormal
+	reason := "AddonAvailable"
+	if availability != metav1.ConditionTrue {
+		eventType = corev1.EventTypeWarning
+		reason = "AddonUnavailable"
+	}
+
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("%s.", cluster.Name),
+			// events for cluster-scoped objects live in the default namespace
+			Namespace: metav1.NamespaceDefault,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: clusterv1.GroupVersion.String(),
+			Kind:       "ManagedCluster",
+			Name:       cluster.Name,
+			UID:        cluster.UID,
+		},
+		Type:           eventType,
+		Reason:         reason,
+		Message:        fmt.Sprintf("The Available condition of addon %q transitioned from %q to %q", c.addonName, previous, availability),
+		Source:         corev1.EventSource{Component: "addon-framework"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	_, err = c.kubeClient.CoreV1().Events(event.Namespace).Create(ctx, event, metav1.CreateOptions{})
+	return err
+}
@@ -0,0 +1,235 @@
+package clustermanagement
+
+import (
+	"context"
+	"fmt"
+
+	"sort"
+	"strings"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/controllers/addonmetrics"
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	addonv1alpha1client "github.com/open-cluster-management/api/client/addon/clientset/versioned"
+	addoninformerv1alpha1 "github.com/open-cluster-management/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "github.com/open-cluster-management/api/client/addon/listers/addon/v1alpha1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/klog/v2"
+)
+
+// clusterManagementController maintains the ClusterManagementAddOn of the addon and keeps the
+// addOnMeta and addOnConfiguration coordinates of the ManagedClusterAddOns in sync with it.
+type clusterManagementController struct {
+	addonName               string
+	addonClient             addonv1alpha1client.Interface
+	clusterManagementLister addonlisterv1alpha1.ClusterManagementAddOnLister
+	addonLister             addonlisterv1alpha1.ManagedClusterAddOnLister
+	createEnabled           bool
+	propagatedAnnotations   []string
+	supportedConfigs        []schema.GroupVersionResource
+	agentLeaseName          string
+	eventRecorder           events.Recorder
+}
+
+// NewClusterManagementController returns a controller reconciling the ClusterManagementAddOn of
+// the addon. If createEnabled is false the ClusterManagementAddOn is never created, e.g. when
+// the resource is owned externally by GitOps, and its absence is reported as an error instead.
+func NewClusterManagementController(
+	addonName string,
+	addonClient addonv1alpha1client.Interface,
+	clusterManagementInformers addoninformerv1alpha1.ClusterManagementAddOnInformer,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	createEnabled bool,
+	propagatedAnnotations []string,
+	supportedConfigs []schema.GroupVersionResource,
+	agentLeaseName string,
+	recorder events.Recorder,
+) factory.Controller {
+	c := &clusterManagementController{
+		addonName:               addonName,
+		addonClient:             addonClient,
+		clusterManagementLister: clusterManagementInformers.Lister(),
+		addonLister:             addonInformers.Lister(),
+		createEnabled:           createEnabled,
+		propagatedAnnotations:   propagatedAnnotations,
+		supportedConfigs:        supportedConfigs,
+		agentLeaseName:          agentLeaseName,
+		eventRecorder:           recorder.WithComponentSuffix("cluster-management-controller"),
+	}
+
+	return factory.New().
+		WithInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				accessor, _ := meta.Accessor(obj)
+				return accessor.GetName()
+			},
+			clusterManagementInformers.Informer()).
+		WithInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				accessor, _ := meta.Accessor(obj)
+				return accessor.GetName()
+			},
+			addonInformers.Informer()).
+		WithSync(addonmetrics.InstrumentSync("ClusterManagementController", c.sync)).
+		ToController("AddonClusterManagementController", recorder)
+}
+
+func (c *clusterManagementController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	addonName := syncCtx.QueueKey()
+	if addonName != c.addonName && addonName != factory.DefaultQueueKey {
+		return nil
+	}
+	klog.V(4).Infof("Reconciling clustermanagementaddon %q", c.addonName)
+
+	clusterManagementAddon, err := c.clusterManagementLister.Get(c.addonName)
+	switch {
+	case errors.IsNotFound(err):
+		if !c.createEnabled {
+			return fmt.Errorf("clustermanagementaddon %q is not found and its auto-creation is disabled", c.addonName)
+		}
+		clusterManagementAddon = &addonapiv1alpha1.ClusterManagementAddOn{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: c.addonName,
+			},
+		}
+		created, createErr := c.addonClient.AddonV1alpha1().ClusterManagementAddOns().Create(
+			ctx, clusterManagementAddon, metav1.CreateOptions{})
+		switch {
+		case errors.IsAlreadyExists(createErr):
+			// another manager instance won the creation race; continue over the live
+			// object instead of the empty result of the failed create
+			clusterManagementAddon, err = c.addonClient.AddonV1alpha1().ClusterManagementAddOns().Get(
+				ctx, c.addonName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+		case createErr != nil:
+			return createErr
+		default:
+			clusterManagementAddon = created
+			c.eventRecorder.Eventf("ClusterManagementAddOnCreated", "Created clustermanagementaddon %q", c.addonName)
+		}
+	case err != nil:
+		return err
+	}
+
+	if err := c.ensureSupportedConfigs(ctx, clusterManagementAddon); err != nil {
+		return err
+	}
+
+	// keep the meta and configuration coordinates of the addons in sync with the
+	// clustermanagementaddon
+	addons, err := c.addonLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	for _, addon := range addons {
+		if addon.Name != c.addonName {
+			continue
+		}
+		if err := c.propagateAnnotations(ctx, clusterManagementAddon, addon); err != nil {
+			return err
+		}
+		if err := c.ensureLeaseNameAnnotation(ctx, addon); err != nil {
+			return err
+		}
+		_, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name,
+			func(status *addonapiv1alpha1.ManagedClusterAddOnStatus) error {
+				status.AddOnMeta = clusterManagementAddon.Spec.AddOnMeta
+				status.AddOnConfiguration = clusterManagementAddon.Spec.AddOnConfiguration
+				return nil
+			})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ensureSupportedConfigs advertises the configuration resources the manager understands on
+// the clustermanagementaddon, so the schema advertisement of the fleet-level object stays in
+// sync with the actual capabilities of the manager. The advertisement is removed when the
+// manager accepts no configuration.
+func (c *clusterManagementController) ensureSupportedConfigs(ctx context.Context, clusterManagementAddon *addonapiv1alpha1.ClusterManagementAddOn) error {
+	coordinates := []string{}
+	for _, gvr := range c.supportedConfigs {
+		coordinates = append(coordinates, fmt.Sprintf("%s/%s/%s", gvr.Group, gvr.Version, gvr.Resource))
+	}
+	sort.Strings(coordinates)
+	advertised := strings.Join(coordinates, ",")
+
+	if clusterManagementAddon.Annotations[constants.SupportedConfigsAnnotation] == advertised &&
+		(len(advertised) > 0 || clusterManagementAddon.Annotations == nil) {
+		return nil
+	}
+	if _, ok := clusterManagementAddon.Annotations[constants.SupportedConfigsAnnotation]; !ok && len(advertised) == 0 {
+		return nil
+	}
+
+	updated := clusterManagementAddon.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	if len(advertised) == 0 {
+		delete(updated.Annotations, constants.SupportedConfigsAnnotation)
+	} else {
+		updated.Annotations[constants.SupportedConfigsAnnotation] = advertised
+	}
+	_, err := c.addonClient.AddonV1alpha1().ClusterManagementAddOns().Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// ensureLeaseNameAnnotation stamps the agent lease name configured with WithAgentLeaseName on
+// the managedclusteraddon, so the lease lookup of the spoke agent finds a lease named outside
+// of the default convention, e.g. an agent migrated from another framework. An annotation set
+// by hand on the addon to a different value wins and is left untouched, keeping the option a
+// fleet-wide default only.
+func (c *clusterManagementController) ensureLeaseNameAnnotation(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+	if len(c.agentLeaseName) == 0 {
+		return nil
+	}
+	if _, ok := addon.Annotations[constants.AddonLeaseNameAnnotation]; ok {
+		return nil
+	}
+
+	updated := addon.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	updated.Annotations[constants.AddonLeaseNameAnnotation] = c.agentLeaseName
+	_, err := c.addonClient.AddonV1alpha1().ManagedClusterAddOns(addon.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// propagateAnnotations copies the configured annotation keys from the clustermanagementaddon
+// down to the given managedclusteraddon. Only the listed keys are touched, and a listed key
+// absent on the clustermanagementaddon is left alone on the addon, so per-install annotations
+// survive the propagation.
+func (c *clusterManagementController) propagateAnnotations(ctx context.Context, clusterManagementAddon *addonapiv1alpha1.ClusterManagementAddOn, addon *addonapiv1alpha1.ManagedClusterAddOn) error {
+	updated := addon.DeepCopy()
+	for _, key := range c.propagatedAnnotations {
+		value, ok := clusterManagementAddon.Annotations[key]
+		if !ok {
+			continue
+		}
+		if updated.Annotations == nil {
+			updated.Annotations = map[string]string{}
+		}
+		updated.Annotations[key] = value
+	}
+	if equality.Semantic.DeepEqual(updated.Annotations, addon.Annotations) {
+		return nil
+	}
+	_, err := c.addonClient.AddonV1alpha1().ManagedClusterAddOns(addon.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,1080 @@
+package addonmanager
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/controllers/addonmetrics"
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/controllers/agentdeploy"
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/controllers/cleanup"
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/controllers/clusterevents"
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/controllers/clustermanagement"
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/registration"
+	"github.com/open-cluster-management/addon-framework/pkg/agent"
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addonclient "github.com/open-cluster-management/api/client/addon/clientset/versioned"
+	addoninformers "github.com/open-cluster-management/api/client/addon/informers/externalversions"
+	clusterclient "github.com/open-cluster-management/api/client/cluster/clientset/versioned"
+	clusterinformers "github.com/open-cluster-management/api/client/cluster/informers/externalversions"
+	workclient "github.com/open-cluster-management/api/client/work/clientset/versioned"
+	workinformers "github.com/open-cluster-management/api/client/work/informers/externalversions"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"github.com/openshift/library-go/pkg/controller/controllercmd"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/dynamic/dynamicinformer"
+	"k8s.io/client-go/informers"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// defaultWorkerCount is the default number of worker goroutines per controller of an addon
+const defaultWorkerCount = 1
+
+// defaultInformerResync is the resync period of the informer factories of the manager
+const defaultInformerResync = 10 * time.Minute
+
+// AddonManager runs the hub-side controllers deploying and maintaining the agent of one addon
+// on the managed clusters.
+type AddonManager struct {
+	addonName                 string
+	agentAddon                agent.AgentAddon
+	overrides                 []agentdeploy.ManifestOverride
+	transformers              []agentdeploy.ManifestTransformer
+	conditionMappers          []agentdeploy.ConditionMapper
+	configDefaulter           func(config runtime.Object) runtime.Object
+	defaultConfig             runtime.Object
+	orderedDeploy             bool
+	upgradeStrategy           agent.UpgradeStrategy
+	cleanupOrphanedWorks      bool
+	deletePropagation         metav1.DeletionPropagation
+	failurePolicy             agent.FailurePolicy
+	maxConcurrentClusters     int
+	agentLeaseName            string
+	clusterManagementCreation bool
+	namespaceCreation         bool
+	namespaceLabels           map[string]string
+	requireClusterAvailable   bool
+	provenance                bool
+	bootstrapRotationInterval time.Duration
+	configResync              time.Duration
+	bootstrapPruning          bool
+	registrationWorkTTL       time.Duration
+	configMapName             string
+	configSelector            map[string]string
+	secretSelector            map[string]string
+	proxyConfigNamespace      string
+	proxyConfigName           string
+	clusterProxyBootstrap     bool
+	clusterAvailabilityEvents bool
+	finalizerName             string
+	clusterFilter             agent.ManagedClusterFilter
+	installScope              agent.InstallScope
+	dependencies              []string
+	clusterNamespaces         []string
+	agentServiceAccountName   string
+	agentServiceAccountNS     string
+	agentMinAvailable         string
+	pullSecretName            string
+	pullSecretData            []byte
+	propagatedAnnotations     []string
+	allowedSystemNamespaces   []string
+	extraInformers            []agentdeploy.ExtraInformer
+	hubCABundle               []byte
+	hubCAConfigMapName        string
+	csrApproveCheck           registration.CSRApproveCheckFunc
+	csrRenewalAuthorizer      registration.CSRRenewalAuthorizer
+	preflightChecks           []PreflightCheck
+	workerCount               int
+	maxWorkSize               int
+	workExecutorName          string
+	workExecutorNamespace     string
+	workWriteQPS              float32
+	workWriteBurst            int
+	reconcileLock             *helpers.KeyedLock
+	transientRequeueDelay     time.Duration
+	workApplyTimeout          time.Duration
+	workAgentGracePeriod      time.Duration
+}
+
+// New returns an AddonManager for the given agent addon
+func New(agentAddon agent.AgentAddon) *AddonManager {
+	return &AddonManager{
+		addonName:                 agentAddon.GetAgentAddonOptions().AddonName,
+		agentAddon:                agentAddon,
+		workAgentGracePeriod:      agentdeploy.DefaultWorkAgentGracePeriod,
+		clusterManagementCreation: true,
+		finalizerName:             constants.AddonDeployFinalizer,
+		workerCount:               defaultWorkerCount,
+		provenance:                true,
+		configResync:              defaultInformerResync,
+		maxWorkSize:               agentdeploy.DefaultMaxManifestWorkSize,
+	}
+}
+
+// WithImageOverrides sets the mapping from the image references in the rendered agent manifests
+// to the images to deploy instead, e.g. the images in a mirror registry of a disconnected
+// environment. The deploy controller rewrites the container images of the workload objects in
+// the manifests before they are packaged into the deploy manifestwork.
+func (m *AddonManager) WithImageOverrides(imageOverrides map[string]string) *AddonManager {
+	m.overrides = append(m.overrides, agentdeploy.NewImageOverride(imageOverrides))
+	return m
+}
+
+// WithNodePlacement merges the given nodeSelector and tolerations into the pod template of the
+// workload objects in the agent manifests, so that operators can pin the agent pods on dedicated
+// node pools without the addon author hardcoding the placement. Objects carrying no pod template
+// are skipped.
+func (m *AddonManager) WithNodePlacement(nodeSelector map[string]string, tolerations []corev1.Toleration) *AddonManager {
+	m.overrides = append(m.overrides, agentdeploy.NewNodePlacementOverride(nodeSelector, tolerations))
+	return m
+}
+
+// WithPodSpecOverrides registers functions mutating the pod spec of every workload object in
+// the agent manifests, e.g. injecting a CA-bundle volume or proxy env vars required in
+// regulated environments, without the addon author exposing a knob for each. The functions
+// run in registration order together with the other per-object overrides; objects without a
+// pod template are skipped.
+func (m *AddonManager) WithPodSpecOverrides(overrides ...func(podSpec *corev1.PodSpec)) *AddonManager {
+	for _, override := range overrides {
+		m.overrides = append(m.overrides, agentdeploy.NewPodSpecOverride(override))
+	}
+	return m
+}
+
+// WithImagePullSecret ships a dockerconfigjson pull secret with the given name and content
+// into the install namespace and references it from the imagePullSecrets of every pod
+// template in the agent manifests, so agents from a private registry need no per-addon
+// wiring; pair it with WithImageOverrides pointing at that registry. A reference the author
+// already declares is kept and never duplicated, and objects without a pod template are
+// skipped.
+func (m *AddonManager) WithImagePullSecret(name string, dockerConfigJSON []byte) *AddonManager {
+	m.pullSecretName = name
+	m.pullSecretData = dockerConfigJSON
+	m.overrides = append(m.overrides, agentdeploy.NewPodSpecOverride(func(podSpec *corev1.PodSpec) {
+		for _, reference := range podSpec.ImagePullSecrets {
+			if reference.Name == name {
+				return
+			}
+		}
+		podSpec.ImagePullSecrets = append(podSpec.ImagePullSecrets, corev1.LocalObjectReference{Name: name})
+	}))
+	return m
+}
+
+// WithManifestTransformer registers a transformer run by the deploy controller on the whole
+// set of rendered agent manifests after AgentManifests and before the per-object overrides. A
+// transformer may add, drop or reorder objects, so arbitrary last-mile policy such as sidecar
+// injection can be implemented without a dedicated framework hook. Transformers compose in
+// registration order, and a transformer failure is reported as a condition on the addon.
+func (m *AddonManager) WithManifestTransformer(transformer agentdeploy.ManifestTransformer) *AddonManager {
+	m.transformers = append(m.transformers, transformer)
+	return m
+}
+
+// WithConditionMapper registers a mapper deriving addon conditions from the status feedback
+// of the deploy manifestworks, merged into the addon status whenever a work status changes.
+// Authors use it to express domain-specific health without owning a controller; the condition
+// types should be namespaced so they do not collide with the ones the framework maintains.
+func (m *AddonManager) WithConditionMapper(mapper agentdeploy.ConditionMapper) *AddonManager {
+	m.conditionMappers = append(m.conditionMappers, mapper)
+	return m
+}
+
+// WithConfigMapConfig selects a config map in the cluster namespace on the hub as the addon
+// configuration source instead of a CR: the config map with the given name is passed to
+// AgentManifests as the config object. It lowers the barrier for addons that do not want to
+// ship a CRD just to hold a few settings. A config change requeues the affected cluster like
+// the CR path does.
+func (m *AddonManager) WithConfigMapConfig(configMapName string) *AddonManager {
+	m.configMapName = configMapName
+	return m
+}
+
+// WithConfigSelector resolves the config CR by the given labels in the cluster namespace
+// instead of the exact CRName of the addOnConfiguration coordinates, supporting templated or
+// generated configs whose names are not known upfront. Exactly one CR must match per cluster
+// namespace; zero or multiple matches fail the sync. The default stays name-based.
+func (m *AddonManager) WithConfigSelector(selector map[string]string) *AddonManager {
+	m.configSelector = selector
+	return m
+}
+
+// WithHubSecretInputs makes the deploy controller watch the secrets of the cluster namespaces
+// matching the given label selector and pass them to the manifest rendering, for addons that
+// template a hub-held credential like a cloud key or a registry token into their spoke
+// manifests. A change to a matching secret re-renders the affected cluster. The agent addon
+// must implement AgentAddonWithHubSecrets, or AgentAddonWithManifestInputs when it combines
+// several render inputs, to receive the secrets.
+func (m *AddonManager) WithHubSecretInputs(selector map[string]string) *AddonManager {
+	m.secretSelector = selector
+	return m
+}
+
+// WithBootstrapWorkPruning removes the registration manifestwork of a managed cluster once
+// the agent reports a valid hub client config on the addon, so the bootstrap credential does
+// not linger on the cluster after it has served its purpose. The work is re-shipped if the
+// bootstrapped condition goes away, e.g. after the hub kubeconfig secret is deleted.
+func (m *AddonManager) WithBootstrapWorkPruning() *AddonManager {
+	m.bootstrapPruning = true
+	return m
+}
+
+// WithManifestWorkTTL removes the registration manifestwork of a managed cluster once the
+// given duration has passed since the addon install was created, whether or not the agent
+// reported a successful bootstrap, as a belt-and-suspenders credential hygiene measure on top
+// of WithBootstrapWorkPruning. The deadline is stamped on the work as an annotation. The TTL
+// must leave a reasonable bootstrap window of at least ten minutes; zero keeps the default of
+// no expiry.
+func (m *AddonManager) WithManifestWorkTTL(ttl time.Duration) *AddonManager {
+	m.registrationWorkTTL = ttl
+	return m
+}
+
+// WithClusterNamespaces scopes the manager to the given cluster namespaces so management of a
+// large hub can be sharded across manager instances. Addons and works outside the given
+// namespaces are ignored entirely, not cleaned up, since another instance owns them. An empty
+// list keeps the default of managing all cluster namespaces.
+func (m *AddonManager) WithClusterNamespaces(namespaces ...string) *AddonManager {
+	m.clusterNamespaces = append(m.clusterNamespaces, namespaces...)
+	return m
+}
+
+// WithManagedClusterFilter sets a predicate deciding which managed clusters the addon manages.
+// The deploy and registration controllers apply it uniformly, so an excluded cluster is skipped
+// everywhere instead of being half-managed; a cluster transitioning from included to excluded
+// has its agent works cleaned up. This is the unifying primitive that label selectors, claim
+// matching and other cluster targeting build on.
+func (m *AddonManager) WithManagedClusterFilter(filter agent.ManagedClusterFilter) *AddonManager {
+	m.clusterFilter = filter
+	return m
+}
+
+// WithAgentServiceAccount standardizes the identity of the agent on the managed clusters: a
+// ServiceAccount with the given name is injected into the agent manifests in the given
+// namespace, defaulting to the install namespace when the namespace is empty. Use the same
+// coordinates with helpers.AgentNamespaceRBAC and helpers.BootstrapKubeConfigFromToken so
+// deploy, RBAC and registration all agree on one identity instead of keeping three places in
+// sync by hand. A service account manifest the author ships with the same coordinates wins.
+func (m *AddonManager) WithAgentServiceAccount(name, namespace string) *AddonManager {
+	m.agentServiceAccountName = name
+	m.agentServiceAccountNS = namespace
+	return m
+}
+
+// WithAgentAvailability hardens the agent workloads for HA operation: a PodDisruptionBudget
+// with the given minAvailable (a number or a percentage) is injected for every Deployment and
+// StatefulSet in the agent manifests, and the given priority class is set on every pod spec
+// carrying none. Addons without a suitable workload ship unchanged, and a budget the author
+// ships for a workload wins. Pass an empty string to leave either knob alone.
+func (m *AddonManager) WithAgentAvailability(minAvailable, priorityClassName string) *AddonManager {
+	m.agentMinAvailable = minAvailable
+	if len(priorityClassName) != 0 {
+		m.overrides = append(m.overrides, agentdeploy.NewPodSpecOverride(func(podSpec *corev1.PodSpec) {
+			if len(podSpec.PriorityClassName) == 0 {
+				podSpec.PriorityClassName = priorityClassName
+			}
+		}))
+	}
+	return m
+}
+
+// WithDependencies declares addons this addon depends on. The deploy controller defers the
+// agent deploy of a managed cluster until every named ManagedClusterAddOn in the same cluster
+// namespace reports the Available condition, with a WaitingForDependency condition on the
+// addon in the meantime, and requeues when a dependency becomes available. Teardown ordering
+// is not enforced; remove dependent addons before their dependencies.
+func (m *AddonManager) WithDependencies(addonNames ...string) *AddonManager {
+	m.dependencies = append(m.dependencies, addonNames...)
+	return m
+}
+
+// WithAddonAnnotationPropagation lists annotation keys copied from the ClusterManagementAddOn
+// down to every ManagedClusterAddOn of the addon, so fleet-wide policy flags set once on the
+// fleet-level object reach each install. Only the listed keys are touched and a listed key
+// absent on the ClusterManagementAddOn is left alone, so per-install annotations survive; the
+// copies re-sync whenever the ClusterManagementAddOn changes.
+func (m *AddonManager) WithAddonAnnotationPropagation(annotationKeys ...string) *AddonManager {
+	m.propagatedAnnotations = append(m.propagatedAnnotations, annotationKeys...)
+	return m
+}
+
+// WithDefaultConfig sets a built-in configuration passed to AgentManifests when no config CR
+// or config map resolves for an install, so addons get sensible out-of-the-box behavior
+// without nil-checking the config in every render. A resolved configuration always overrides
+// the default, and the default is deep-copied per render so a render can never mutate it.
+func (m *AddonManager) WithDefaultConfig(config runtime.Object) *AddonManager {
+	m.defaultConfig = config
+	return m
+}
+
+// WithExtraInformers registers watches on resources beyond the cluster and the config CR that
+// drive the rendering, e.g. a secret in the cluster namespaces or a cluster-scoped
+// infrastructure object. Events on a watched resource requeue the install its queue key
+// function points at, so the render picks up the change without waiting for a resync; read
+// the watched resources through the lister of the informer in AgentManifests. The manager
+// starts the informers together with its own.
+func (m *AddonManager) WithExtraInformers(extraInformers ...agentdeploy.ExtraInformer) *AddonManager {
+	m.extraInformers = append(m.extraInformers, extraInformers...)
+	return m
+}
+
+// WithHubCABundle configures the CA bundle agents use to validate the serving certificate of
+// the hub. The bundle is embedded into kubeconfigs assembled by
+// helpers.BootstrapKubeConfigFromToken and into the config map injected by
+// WithHubCAConfigMap, so the bootstrap and runtime trust stores come from one source instead
+// of drifting apart. Pass nil to discover the CA from the rest config of the manager, which
+// is also the default when this option is not used.
+func (m *AddonManager) WithHubCABundle(caBundle []byte) *AddonManager {
+	m.hubCABundle = caBundle
+	return m
+}
+
+// WithHubCAConfigMap injects a config map with the given name carrying the hub CA bundle into
+// the deploy work, in the install namespace of the agent under the constants.HubCABundleKey
+// key, so the agent mounts the same CA the bootstrap kubeconfig trusts. The bundle is the one
+// configured with WithHubCABundle, or the CA discovered from the rest config of the manager.
+func (m *AddonManager) WithHubCAConfigMap(name string) *AddonManager {
+	m.hubCAConfigMapName = name
+	return m
+}
+
+// WithClusterAvailabilityEvents mirrors availability transitions of the addon as kubernetes
+// events referencing the ManagedCluster, so cluster-centric dashboards catch addon
+// degradation without watching the per-addon conditions. Events fire on transitions of the
+// Available condition only, never on steady state, and the option is off by default to avoid
+// event volume on large fleets.
+func (m *AddonManager) WithClusterAvailabilityEvents() *AddonManager {
+	m.clusterAvailabilityEvents = true
+	return m
+}
+
+// WithReconcileConcurrencyKey serializes the reconciles of the manager controllers per
+// cluster: the deploy and registration controllers share a keyed lock on the cluster
+// namespace, so their read-modify-write cycles on the same ManagedClusterAddOn never
+// interleave. The tradeoff is reduced parallelism when several controllers act on one cluster
+// at the same time; leave it off unless conflicting writes show up with multiple workers.
+func (m *AddonManager) WithReconcileConcurrencyKey() *AddonManager {
+	m.reconcileLock = helpers.NewKeyedLock()
+	return m
+}
+
+// WithClusterProxyIntegration routes the registration bootstrap of the agents through a
+// cluster-proxy deployment: the bootstrap kubeconfig is rewritten to the proxy endpoint and
+// CA published in the config map configured with WithProxyConfigMap, so agents on
+// network-isolated clusters can still reach the hub. The integration degrades to the direct
+// hub endpoint while the config map publishes no endpoint, and re-ships the bootstrap once
+// one appears.
+func (m *AddonManager) WithClusterProxyIntegration() *AddonManager {
+	m.clusterProxyBootstrap = true
+	return m
+}
+
+// WithSystemNamespaceAllowlist allows installing the agent into the listed protected system
+// namespaces, which the deploy and registration paths otherwise reject. Deploying addon agents
+// into kube-system is a common footgun with real blast-radius consequences; allowlist a system
+// namespace only when the agent genuinely has to live next to the cluster control plane.
+func (m *AddonManager) WithSystemNamespaceAllowlist(namespaces ...string) *AddonManager {
+	m.allowedSystemNamespaces = append(m.allowedSystemNamespaces, namespaces...)
+	return m
+}
+
+// WithFinalizerName overrides the finalizer the deploy controller places on the
+// ManagedClusterAddOn, defaulting to constants.AddonDeployFinalizer. Two manager instances
+// managing overlapping addons, e.g. different framework versions during a migration, would
+// otherwise fight over the same finalizer; give each instance its own name so one instance
+// never removes a finalizer still guarding the cleanup of the other.
+func (m *AddonManager) WithFinalizerName(finalizerName string) *AddonManager {
+	m.finalizerName = finalizerName
+	return m
+}
+
+// WithProxyConfigMap names the config map on the hub describing the endpoint of a
+// cluster-proxy deployment. When set, the resolved proxy URL is handed to addons implementing
+// AgentAddonWithConnectivity so their agents can route traffic through the proxy; when the
+// config map does not exist the proxy URL stays empty and agents talk to the hub directly.
+func (m *AddonManager) WithProxyConfigMap(namespace, name string) *AddonManager {
+	m.proxyConfigNamespace = namespace
+	m.proxyConfigName = name
+	return m
+}
+
+// WithConfigInformerResync sets the resync period of the addon configuration informer
+// independently of the fixed period of the other informers, so a frequently changing config
+// converges faster without raising the watch load of the cluster and work informers. The
+// default stays at ten minutes.
+func (m *AddonManager) WithConfigInformerResync(resync time.Duration) *AddonManager {
+	if resync > 0 {
+		m.configResync = resync
+	}
+	return m
+}
+
+// WithConfigDefaulter sets a function filling in the defaults of the addon configuration
+// resource before it is passed to AgentManifests, so that defaulting of optional fields lives
+// in one place instead of every manifest rendering implementation. The defaulter always
+// receives a deep copy of the fetched configuration.
+func (m *AddonManager) WithConfigDefaulter(defaulter func(config runtime.Object) runtime.Object) *AddonManager {
+	m.configDefaulter = defaulter
+	return m
+}
+
+// WithDeleteStrategy sets the propagation policy of the delete calls across the cleanup
+// paths, i.e. how the manifestworks of the addon are deleted when an addon or a cluster goes
+// away. Foreground makes the deletes wait for dependents for an ordered teardown, which pairs
+// well with the uninstall-complete condition; the default keeps the background propagation of
+// the apiserver.
+func (m *AddonManager) WithDeleteStrategy(policy metav1.DeletionPropagation) *AddonManager {
+	m.deletePropagation = policy
+	return m
+}
+
+// WithAgentLeaseName overrides the name of the lease the agent availability is derived from,
+// which defaults to the addon name. Use it to adopt an existing agent whose lease follows
+// another convention, e.g. one migrated from a different framework, without renaming its
+// lease. The name is stamped on every install of the addon as the lease name annotation; an
+// annotation set by hand on an install wins.
+func (m *AddonManager) WithAgentLeaseName(leaseName string) *AddonManager {
+	m.agentLeaseName = leaseName
+	return m
+}
+
+// WithMaxConcurrentClusters bounds how many clusters are actively rolling out a pending
+// change of the addon at once. Clusters beyond the limit are held with a RolloutQueued
+// condition until earlier ones report available, so a bad manifest is caught on the first few
+// clusters instead of the whole fleet. Zero keeps the default of an unlimited rollout.
+func (m *AddonManager) WithMaxConcurrentClusters(maxClusters int) *AddonManager {
+	m.maxConcurrentClusters = maxClusters
+	return m
+}
+
+// WithFailurePolicy declares how failures of individual agent manifests reflect in the
+// ManifestsDegraded condition of the addon. The default All policy degrades the addon on any
+// failed manifest; BestEffort degrades it only when a manifest flagged with the critical
+// annotation fails, for addons whose optional manifests may legitimately fail on some
+// clusters.
+func (m *AddonManager) WithFailurePolicy(policy agent.FailurePolicy) *AddonManager {
+	m.failurePolicy = policy
+	return m
+}
+
+// WithOrderedDeploy splits the agent manifests into ordered deploy waves so that manifests
+// with ordering requirements, e.g. a CRD that must be established before its CR is created,
+// are applied in sequence. CRDs are applied first, then namespaces, then everything else, and
+// a manifest can be assigned to a wave explicitly with the deploy wave annotation.
+func (m *AddonManager) WithOrderedDeploy() *AddonManager {
+	m.orderedDeploy = true
+	return m
+}
+
+// WithAgentUpgradeStrategy declares how changed agent manifests are rolled out to the managed
+// clusters. With the Recreate strategy, a changed manifest the author flagged with the
+// recreate-on-change annotation is first removed from the deploy work so the work agent
+// deletes it, and the new version is applied on a follow-up reconcile; stateful agents that
+// cannot survive an in-place update across a breaking change need this. The default rolling
+// update applies changes in place.
+func (m *AddonManager) WithAgentUpgradeStrategy(strategy agent.UpgradeStrategy) *AddonManager {
+	m.upgradeStrategy = strategy
+	return m
+}
+
+// WithWorkerCount sets the number of worker goroutines each controller of this addon runs
+// with. The workers, queues and rate limiters are owned by the manager instance, so each addon
+// in a process gets its own and a slow AgentManifests or a blocking apply wait on one addon
+// never starves the others. The default is one worker per controller.
+func (m *AddonManager) WithWorkerCount(workers int) *AddonManager {
+	if workers > 0 {
+		m.workerCount = workers
+	}
+	return m
+}
+
+// WithMaxManifestWorkSize sets the limit on the serialized size of a deploy manifestwork. A
+// work over the limit fails the sync with an OversizedManifestWork condition carrying the
+// actual size, instead of an opaque apiserver rejection on Create or Update.
+func (m *AddonManager) WithMaxManifestWorkSize(bytes int) *AddonManager {
+	if bytes > 0 {
+		m.maxWorkSize = bytes
+	}
+	return m
+}
+
+// WithTransientErrorRequeueDelay sets the delay a sync failing on a known-transient API
+// error, e.g. a conflict or apiserver throttling, is requeued with. The default of thirty
+// seconds keeps the controllers from hammering the apiserver with the tight per-item backoff
+// during a sustained outage; fatal errors keep the default rate limited retry.
+func (m *AddonManager) WithTransientErrorRequeueDelay(delay time.Duration) *AddonManager {
+	m.transientRequeueDelay = delay
+	return m
+}
+
+// WithWorkExecutor names the service account on the managed cluster the work agent should
+// apply the manifests of this addon as, instead of the full privileges of the klusterlet, to
+// constrain the addon on multi-tenant spokes. Both works of the addon carry the executor; for
+// now it is surfaced as an annotation until the vendored work API carries the Executor field.
+func (m *AddonManager) WithWorkExecutor(saName, saNamespace string) *AddonManager {
+	m.workExecutorName = saName
+	m.workExecutorNamespace = saNamespace
+	return m
+}
+
+// WithWorkWriteRate bounds the rate of manifestwork Create and Update calls of this manager
+// instance with a token bucket, so a mass reconcile after a hub restart or a manager upgrade
+// does not burst-write the whole fleet to the work API at once. No-op applies are not
+// throttled. The default is unlimited.
+func (m *AddonManager) WithWorkWriteRate(qps float32, burst int) *AddonManager {
+	m.workWriteQPS = qps
+	m.workWriteBurst = burst
+	return m
+}
+
+// WithWorkApplyTimeout makes the deploy sync block until the deploy manifestwork reports the
+// Applied condition, failing the sync if it does not within the given timeout. It is a
+// stricter alternative to the passive work status mirroring for automated rollouts that need
+// a failed apply to surface as an error. The default is non-blocking.
+func (m *AddonManager) WithWorkApplyTimeout(timeout time.Duration) *AddonManager {
+	m.workApplyTimeout = timeout
+	return m
+}
+
+// WithWorkAgentGracePeriod sets how long a deploy manifestwork may exist without any Applied
+// status before the WorkAgentUnresponsive condition is reported on the ManagedClusterAddOn.
+func (m *AddonManager) WithWorkAgentGracePeriod(period time.Duration) *AddonManager {
+	m.workAgentGracePeriod = period
+	return m
+}
+
+// WithSpokeInstallScope declares how the agent installs on the managed clusters. The
+// SingleNamespace scope turns on the managed install namespace like WithNamespaceCreation
+// and expects namespaced RBAC; the Cluster scope expects cluster-scoped resources and
+// ClusterRole-based RBAC, see the AgentClusterRBAC helper. The default keeps inferring the
+// behavior from the manifests the author ships.
+func (m *AddonManager) WithSpokeInstallScope(scope agent.InstallScope) *AddonManager {
+	m.installScope = scope
+	if scope == agent.InstallScopeSingleNamespace {
+		m.namespaceCreation = true
+	}
+	return m
+}
+
+// WithNamespaceCreation makes the deploy controller create the install namespace of the agent
+// as part of the deploy manifestwork. The namespace then follows the lifecycle of the addon:
+// it is created with the first deploy and removed by the work agent when the addon is deleted.
+// If the author ships the install namespace in AgentManifests already, the author-provided
+// manifest wins and this option is a no-op.
+func (m *AddonManager) WithNamespaceCreation() *AddonManager {
+	m.namespaceCreation = true
+	return m
+}
+
+// WithInstallNamespaceLabels sets extra labels on the install namespace the deploy controller
+// creates with WithNamespaceCreation, so the namespace participates in label-keyed policies
+// like NetworkPolicies and ResourceQuotas without the author shipping it. The labels are
+// merged into an author-shipped install namespace manifest as well, with author-set keys
+// winning. The option has no effect while the namespace creation is off.
+func (m *AddonManager) WithInstallNamespaceLabels(labels map[string]string) *AddonManager {
+	m.namespaceLabels = labels
+	return m
+}
+
+// WithoutProvenanceAnnotations disables the provenance annotations the deploy controller
+// stamps on every deployed resource by default, naming the owning addon and the source
+// cluster namespace. Opt out only when the annotations clash with tooling on the managed
+// cluster; they are what makes "where did this resource come from" answerable on the spoke.
+func (m *AddonManager) WithoutProvenanceAnnotations() *AddonManager {
+	m.provenance = false
+	return m
+}
+
+// WithDeployOnAvailableCluster defers the agent deploy of a managed cluster until the cluster
+// reports the Available condition, with a WaitingForClusterAvailable condition on the addon in
+// the meantime. Works shipped to an offline cluster only sit unapplied; deferring keeps the
+// status honest. The default deploys regardless of cluster availability.
+func (m *AddonManager) WithDeployOnAvailableCluster() *AddonManager {
+	m.requireClusterAvailable = true
+	return m
+}
+
+// WithBootstrapKubeconfigRotation re-invokes AgentBootstrapKubeConfig on the given interval so
+// a rotated bootstrap credential is re-shipped to the managed clusters without manual
+// intervention. A changed bootstrap kubeconfig is always re-shipped on the next reconcile
+// regardless of this interval; the interval only guarantees an upper bound.
+func (m *AddonManager) WithBootstrapKubeconfigRotation(interval time.Duration) *AddonManager {
+	m.bootstrapRotationInterval = interval
+	return m
+}
+
+// WithClusterManagementCreation controls whether the manager creates the ClusterManagementAddOn
+// of the addon when it is absent. Disable it when the resource is owned externally, e.g. by a
+// GitOps pipeline; the absence is then reported as an error instead of the controller fighting
+// the external owner.
+func (m *AddonManager) WithClusterManagementCreation(enabled bool) *AddonManager {
+	m.clusterManagementCreation = enabled
+	return m
+}
+
+// WithPreflightChecks registers checks run once at the start of Run, after the hub clients
+// are built and before any controller starts. They complement the built-in checks verifying
+// the addon, cluster and work CRDs are installed; all failures are aggregated into one
+// startup error so every unmet prerequisite surfaces at once.
+func (m *AddonManager) WithPreflightChecks(checks ...PreflightCheck) *AddonManager {
+	m.preflightChecks = append(m.preflightChecks, checks...)
+	return m
+}
+
+// WithEnableCSRApproveFunc enables the auto approval of the CSRs of the addon agents with the
+// given approve check, e.g. the built-in CSRRenewalApproveCheck or ApproveByClusterClaim of
+// the registration package, or a custom policy.
+func (m *AddonManager) WithEnableCSRApproveFunc(approveCheck registration.CSRApproveCheckFunc) *AddonManager {
+	m.csrApproveCheck = approveCheck
+	return m
+}
+
+// WithCSRRenewalSubjectAccessReview replaces the built-in subject access review run before a
+// CSR of the addon agent is auto approved. The given authorizer receives the CSR and returns
+// whether the request is allowed with a reason for a denial; a denied CSR is left pending for
+// a manual decision. Use it to route the renewal authorization through an external policy
+// engine; by default the requesting user is checked for the renew verb on the client
+// certificates of the addon.
+func (m *AddonManager) WithCSRRenewalSubjectAccessReview(authorizer registration.CSRRenewalAuthorizer) *AddonManager {
+	m.csrRenewalAuthorizer = authorizer
+	return m
+}
+
+// WithOrphanCleanup enables a sweep removing the manifestworks carrying the addon work label
+// whose addon no longer exists, e.g. works left behind after the addon name of the manager
+// changed. It is off by default since it deletes works not created by this manager instance.
+func (m *AddonManager) WithOrphanCleanup() *AddonManager {
+	m.cleanupOrphanedWorks = true
+	return m
+}
+
+// Validate checks the accumulated options for combinations that cannot work, so a
+// misconfigured manager fails fast with a clear error instead of surfacing the problem only
+// after the informers have started. Run calls it implicitly; call it explicitly to validate
+// options before wiring the manager into a command.
+func (m *AddonManager) Validate() error {
+	if len(m.addonName) == 0 {
+		return fmt.Errorf("addon name must be set in the agent addon options")
+	}
+
+	_, hasRegistration := m.agentAddon.(agent.AgentAddonWithRegistration)
+	if m.csrApproveCheck != nil && !hasRegistration {
+		return fmt.Errorf("WithEnableCSRApproveFunc requires the agent addon to implement AgentAddonWithRegistration")
+	}
+	if m.csrRenewalAuthorizer != nil && m.csrApproveCheck == nil {
+		return fmt.Errorf("WithCSRRenewalSubjectAccessReview requires the CSR auto approval enabled with WithEnableCSRApproveFunc")
+	}
+	if m.bootstrapRotationInterval > 0 && !hasRegistration {
+		return fmt.Errorf("WithBootstrapKubeconfigRotation requires the agent addon to implement AgentAddonWithRegistration")
+	}
+	if m.bootstrapPruning && !hasRegistration {
+		return fmt.Errorf("WithBootstrapWorkPruning requires the agent addon to implement AgentAddonWithRegistration")
+	}
+	if m.registrationWorkTTL != 0 && !hasRegistration {
+		return fmt.Errorf("WithManifestWorkTTL requires the agent addon to implement AgentAddonWithRegistration")
+	}
+	if m.registrationWorkTTL != 0 && m.registrationWorkTTL < 10*time.Minute {
+		return fmt.Errorf("the manifestwork TTL %s leaves no reasonable bootstrap window; it must be at least ten minutes", m.registrationWorkTTL)
+	}
+	if m.clusterProxyBootstrap && !hasRegistration {
+		return fmt.Errorf("WithClusterProxyIntegration requires the agent addon to implement AgentAddonWithRegistration")
+	}
+	if m.clusterProxyBootstrap && (len(m.proxyConfigNamespace) == 0 || len(m.proxyConfigName) == 0) {
+		return fmt.Errorf("WithClusterProxyIntegration requires the proxy config map set with WithProxyConfigMap")
+	}
+
+	switch m.deletePropagation {
+	case "", metav1.DeletePropagationBackground, metav1.DeletePropagationForeground:
+	default:
+		return fmt.Errorf("unsupported delete propagation policy %q", m.deletePropagation)
+	}
+
+	renderInterfaces := []string{}
+	if _, ok := m.agentAddon.(agent.AgentAddonWithConnectivity); ok {
+		renderInterfaces = append(renderInterfaces, "AgentAddonWithConnectivity")
+	}
+	if _, ok := m.agentAddon.(agent.AgentAddonWithManifestIDs); ok {
+		renderInterfaces = append(renderInterfaces, "AgentAddonWithManifestIDs")
+	}
+	if _, ok := m.agentAddon.(agent.AgentAddonWithHubSecrets); ok {
+		renderInterfaces = append(renderInterfaces, "AgentAddonWithHubSecrets")
+	}
+	if _, ok := m.agentAddon.(agent.AgentAddonWithInstalledAddons); ok {
+		renderInterfaces = append(renderInterfaces, "AgentAddonWithInstalledAddons")
+	}
+	if _, ok := m.agentAddon.(agent.AgentAddonWithDeployedDigest); ok {
+		renderInterfaces = append(renderInterfaces, "AgentAddonWithDeployedDigest")
+	}
+	_, composedRenderInputs := m.agentAddon.(agent.AgentAddonWithManifestInputs)
+	if !composedRenderInputs && len(renderInterfaces) > 1 {
+		return fmt.Errorf("the agent addon implements %s, whose render inputs would shadow each other; implement AgentAddonWithManifestInputs to combine them", strings.Join(renderInterfaces, " and "))
+	}
+
+	if len(m.secretSelector) > 0 {
+		if _, ok := m.agentAddon.(agent.AgentAddonWithHubSecrets); !ok && !composedRenderInputs {
+			return fmt.Errorf("WithHubSecretInputs requires the agent addon to implement AgentAddonWithHubSecrets or AgentAddonWithManifestInputs")
+		}
+	}
+
+	if len(m.agentLeaseName) > 0 {
+		if errs := validation.IsDNS1123Subdomain(m.agentLeaseName); len(errs) > 0 {
+			return fmt.Errorf("invalid agent lease name %q: %s", m.agentLeaseName, strings.Join(errs, ", "))
+		}
+	}
+	if m.maxConcurrentClusters < 0 {
+		return fmt.Errorf("max concurrent clusters must not be negative")
+	}
+
+	switch m.failurePolicy {
+	case "", agent.FailurePolicyAll, agent.FailurePolicyBestEffort:
+	default:
+		return fmt.Errorf("unknown failure policy %q", m.failurePolicy)
+	}
+
+	switch m.upgradeStrategy {
+	case "", agent.UpgradeStrategyRollingUpdate, agent.UpgradeStrategyRecreate:
+	default:
+		return fmt.Errorf("unknown upgrade strategy %q", m.upgradeStrategy)
+	}
+
+	switch m.installScope {
+	case agent.InstallScopeDefault, agent.InstallScopeSingleNamespace, agent.InstallScopeCluster:
+	default:
+		return fmt.Errorf("unknown install scope %q", m.installScope)
+	}
+	if m.installScope == agent.InstallScopeSingleNamespace &&
+		len(m.agentAddon.GetAgentAddonOptions().AddonInstallNamespace) == 0 &&
+		m.agentAddon.GetAgentAddonOptions().AddonInstallNamespaceFn == nil {
+		return fmt.Errorf("the SingleNamespace install scope requires an install namespace in the agent addon options")
+	}
+
+	if namespace := m.agentAddon.GetAgentAddonOptions().AddonInstallNamespace; helpers.IsProtectedNamespace(namespace) {
+		allowed := false
+		for _, allowedNamespace := range m.allowedSystemNamespaces {
+			if allowedNamespace == namespace {
+				allowed = true
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("the install namespace %q is a protected system namespace; allowlist it with WithSystemNamespaceAllowlist if this is intended", namespace)
+		}
+	}
+
+	if len(m.configMapName) != 0 && !m.agentAddon.GetAgentAddonOptions().AddonConfigGVR.Empty() {
+		return fmt.Errorf("WithConfigMapConfig cannot be combined with an AddonConfigGVR, only one configuration source is supported")
+	}
+	if len(m.configSelector) != 0 && m.agentAddon.GetAgentAddonOptions().AddonConfigGVR.Empty() {
+		return fmt.Errorf("WithConfigSelector requires an AddonConfigGVR in the agent addon options")
+	}
+
+	if len(m.workExecutorName) != 0 || len(m.workExecutorNamespace) != 0 {
+		if len(m.workExecutorName) == 0 || len(m.workExecutorNamespace) == 0 {
+			return fmt.Errorf("WithWorkExecutor requires both a service account name and a namespace")
+		}
+		if errs := validation.IsDNS1123Subdomain(m.workExecutorName); len(errs) > 0 {
+			return fmt.Errorf("invalid work executor service account name %q: %s", m.workExecutorName, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsDNS1123Label(m.workExecutorNamespace); len(errs) > 0 {
+			return fmt.Errorf("invalid work executor namespace %q: %s", m.workExecutorNamespace, strings.Join(errs, ", "))
+		}
+	}
+
+	return nil
+}
+
+// RenderManifests runs the configured deploy manifest pipeline for the given cluster and
+// configuration without a hub connection and returns the work manifests in deploy order: the
+// same rendering, transformers, overrides and decorations the deploy controller would apply.
+// The optional render inputs (connectivity, hub secrets, deployed digest, installed addons)
+// carry their zero values, matching a fresh install on a bare hub.
+func (m *AddonManager) RenderManifests(cluster *clusterv1.ManagedCluster, config runtime.Object) ([]workapiv1.Manifest, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	pipeline := agentdeploy.NewManifestPipeline(
+		m.addonName,
+		m.agentAddon,
+		nil,
+		m.overrides,
+		m.transformers,
+		m.namespaceCreation,
+		m.namespaceLabels,
+		m.agentServiceAccountName,
+		m.agentServiceAccountNS,
+		m.agentMinAvailable,
+		m.pullSecretName,
+		m.pullSecretData,
+		m.hubCABundle,
+		m.hubCAConfigMapName,
+		m.provenance)
+	return pipeline.Render(cluster, config)
+}
+
+// Run starts the addon manager controllers and blocks until the context is cancelled
+func (m *AddonManager) Run(ctx context.Context, controllerContext *controllercmd.ControllerContext) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	addonClient, err := addonclient.NewForConfig(controllerContext.KubeConfig)
+	if err != nil {
+		return err
+	}
+	clusterClient, err := clusterclient.NewForConfig(controllerContext.KubeConfig)
+	if err != nil {
+		return err
+	}
+	workClient, err := workclient.NewForConfig(controllerContext.KubeConfig)
+	if err != nil {
+		return err
+	}
+	dynamicClient, err := dynamic.NewForConfig(controllerContext.KubeConfig)
+	if err != nil {
+		return err
+	}
+	kubeClient, err := kubernetes.NewForConfig(controllerContext.KubeConfig)
+	if err != nil {
+		return err
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(controllerContext.KubeConfig)
+	if err != nil {
+		return err
+	}
+	restMapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+
+	hubCABundle := m.hubCABundle
+	if len(m.hubCAConfigMapName) != 0 {
+		// resolve the bundle once at startup; the deploy controller ships it as-is
+		hubCABundle, err = helpers.HubCABundle(controllerContext.KubeConfig, m.hubCABundle)
+		if err != nil {
+			return err
+		}
+	}
+
+	var workWriteLimiter flowcontrol.RateLimiter
+	if m.workWriteQPS > 0 {
+		workWriteLimiter = flowcontrol.NewTokenBucketRateLimiter(m.workWriteQPS, m.workWriteBurst)
+	}
+
+	preflightClients := PreflightClients{
+		KubeClient:      kubeClient,
+		AddonClient:     addonClient,
+		WorkClient:      workClient,
+		DiscoveryClient: discoveryClient,
+	}
+	if err := runPreflightChecks(ctx, preflightClients, m.preflightChecks); err != nil {
+		return err
+	}
+
+	kubeInformerFactory := informers.NewSharedInformerFactory(kubeClient, defaultInformerResync)
+	addonInformerOptions := []addoninformers.SharedInformerOption{}
+	workInformerOptions := []workinformers.SharedInformerOption{}
+	if len(m.clusterNamespaces) == 1 {
+		// a single-namespace shard can be scoped at the informer level; larger shards
+		// watch everything and filter in the controllers
+		addonInformerOptions = append(addonInformerOptions, addoninformers.WithNamespace(m.clusterNamespaces[0]))
+		workInformerOptions = append(workInformerOptions, workinformers.WithNamespace(m.clusterNamespaces[0]))
+	}
+	addonInformerFactory := addoninformers.NewSharedInformerFactoryWithOptions(addonClient, defaultInformerResync, addonInformerOptions...)
+	clusterInformerFactory := clusterinformers.NewSharedInformerFactory(clusterClient, defaultInformerResync)
+	workInformerFactory := workinformers.NewSharedInformerFactoryWithOptions(workClient, defaultInformerResync, workInformerOptions...)
+
+	// the config informer gets its own factory so its resync period can be tuned
+	// independently of the other informers
+	var configInformer informers.GenericInformer
+	var dynamicInformerFactory dynamicinformer.DynamicSharedInformerFactory
+	if gvr := m.agentAddon.GetAgentAddonOptions().AddonConfigGVR; !gvr.Empty() {
+		dynamicInformerFactory = dynamicinformer.NewDynamicSharedInformerFactory(dynamicClient, m.configResync)
+		configInformer = dynamicInformerFactory.ForResource(gvr)
+	}
+
+	// the secret informer is only instantiated when secret inputs are configured, so a
+	// manager without them never watches secrets fleet-wide
+	var secretInformer corev1informers.SecretInformer
+	if len(m.secretSelector) > 0 {
+		secretInformer = kubeInformerFactory.Core().V1().Secrets()
+	}
+	deployController := agentdeploy.NewAddonDeployController(
+		m.addonName,
+		m.agentAddon,
+		addonClient,
+		workClient,
+		dynamicClient,
+		addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns(),
+		clusterInformerFactory.Cluster().V1().ManagedClusters(),
+		workInformerFactory.Work().V1().ManifestWorks(),
+		restMapper,
+		m.clusterNamespaces,
+		m.clusterFilter,
+		m.finalizerName,
+		m.dependencies,
+		m.overrides,
+		m.transformers,
+		m.conditionMappers,
+		m.namespaceCreation,
+		m.namespaceLabels,
+		m.allowedSystemNamespaces,
+		m.agentServiceAccountName,
+		m.agentServiceAccountNS,
+		m.agentMinAvailable,
+		m.pullSecretName,
+		m.pullSecretData,
+		hubCABundle,
+		m.hubCAConfigMapName,
+		m.provenance,
+		m.requireClusterAvailable,
+		m.configMapName,
+		m.configSelector,
+		kubeInformerFactory.Core().V1().ConfigMaps(),
+		m.secretSelector,
+		secretInformer,
+		configInformer,
+		m.extraInformers,
+		controllerContext.KubeConfig.Host,
+		m.proxyConfigNamespace,
+		m.proxyConfigName,
+		m.configDefaulter,
+		m.defaultConfig,
+		m.orderedDeploy,
+		m.upgradeStrategy,
+		m.deletePropagation,
+		m.failurePolicy,
+		m.maxConcurrentClusters,
+		m.maxWorkSize,
+		m.workExecutorName,
+		m.workExecutorNamespace,
+		workWriteLimiter,
+		m.reconcileLock,
+		m.transientRequeueDelay,
+		m.workApplyTimeout,
+		m.workAgentGracePeriod,
+		controllerContext.EventRecorder,
+	)
+
+	proxyBootstrapNamespace, proxyBootstrapName := "", ""
+	if m.clusterProxyBootstrap {
+		proxyBootstrapNamespace, proxyBootstrapName = m.proxyConfigNamespace, m.proxyConfigName
+	}
+	if registrationAgentAddon, ok := m.agentAddon.(agent.AgentAddonWithRegistration); ok {
+		registrationController := registration.NewRegistrationAgentDeployController(
+			m.addonName,
+			registrationAgentAddon,
+			addonClient,
+			m.clusterNamespaces,
+			m.clusterFilter,
+			workClient,
+			addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns(),
+			clusterInformerFactory.Cluster().V1().ManagedClusters(),
+			workInformerFactory.Work().V1().ManifestWorks(),
+			m.bootstrapRotationInterval,
+			m.bootstrapPruning,
+			m.registrationWorkTTL,
+			m.transientRequeueDelay,
+			m.workExecutorName,
+			m.workExecutorNamespace,
+			workWriteLimiter,
+			m.reconcileLock,
+			m.allowedSystemNamespaces,
+			m.deletePropagation,
+			proxyBootstrapNamespace,
+			proxyBootstrapName,
+			kubeInformerFactory.Core().V1().ConfigMaps(),
+			controllerContext.EventRecorder,
+		)
+		go registrationController.Run(ctx, m.workerCount)
+	}
+
+	if m.csrApproveCheck != nil {
+		csrApproveController := registration.NewCSRApproveController(
+			m.addonName,
+			kubeClient,
+			clusterInformerFactory.Cluster().V1().ManagedClusters(),
+			kubeInformerFactory.Certificates().V1().CertificateSigningRequests(),
+			m.csrApproveCheck,
+			m.csrRenewalAuthorizer,
+			controllerContext.EventRecorder,
+		)
+		go csrApproveController.Run(ctx, m.workerCount)
+	}
+
+	supportedConfigs := []schema.GroupVersionResource{}
+	if gvr := m.agentAddon.GetAgentAddonOptions().AddonConfigGVR; !gvr.Empty() {
+		supportedConfigs = append(supportedConfigs, gvr)
+	}
+	if len(m.configMapName) != 0 {
+		supportedConfigs = append(supportedConfigs, schema.GroupVersionResource{Version: "v1", Resource: "configmaps"})
+	}
+	clusterManagementController := clustermanagement.NewClusterManagementController(
+		m.addonName,
+		addonClient,
+		addonInformerFactory.Addon().V1alpha1().ClusterManagementAddOns(),
+		addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns(),
+		m.clusterManagementCreation,
+		m.propagatedAnnotations,
+		supportedConfigs,
+		m.agentLeaseName,
+		controllerContext.EventRecorder,
+	)
+	go clusterManagementController.Run(ctx, m.workerCount)
+
+	if m.clusterAvailabilityEvents {
+		clusterEventsController := clusterevents.NewClusterEventsController(
+			m.addonName,
+			kubeClient,
+			addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns(),
+			clusterInformerFactory.Cluster().V1().ManagedClusters(),
+			controllerContext.EventRecorder,
+		)
+		go clusterEventsController.Run(ctx, m.workerCount)
+	}
+
+	metricsController := addonmetrics.NewAddonMetricsController(
+		m.addonName,
+		addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns(),
+		controllerContext.EventRecorder,
+	)
+	go metricsController.Run(ctx, m.workerCount)
+
+	if m.cleanupOrphanedWorks {
+		orphanWorkController := cleanup.NewOrphanWorkController(
+			workClient,
+			addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns(),
+			workInformerFactory.Work().V1().ManifestWorks(),
+			m.deletePropagation,
+			controllerContext.EventRecorder,
+		)
+		go orphanWorkController.Run(ctx, m.workerCount)
+	}
+
+	if dynamicInformerFactory != nil {
+		dynamicInformerFactory.Start(ctx.Done())
+	}
+	for _, extraInformer := range m.extraInformers {
+		if extraInformer.Informer == nil {
+			continue
+		}
+		go extraInformer.Informer.Run(ctx.Done())
+	}
+	go kubeInformerFactory.Start(ctx.Done())
+	go addonInformerFactory.Start(ctx.Done())
+	go clusterInformerFactory.Start(ctx.Done())
+	go workInformerFactory.Start(ctx.Done())
+
+	go deployController.Run(ctx, m.workerCount)
+
+	<-ctx.Done()
+	return nil
+}
@@ -0,0 +1,115 @@
+package addonmanager
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	testinghelpers "github.com/open-cluster-management/addon-framework/pkg/helpers/testing"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func approveEverything(cluster *clusterv1.ManagedCluster, csr *certificatesv1.CertificateSigningRequest) bool {
+	return true
+}
+
+func TestValidate(t *testing.T) {
+	plainAddon := func() *testinghelpers.FakeAgentAddon {
+		return testinghelpers.NewFakeAgentAddon("test", nil, nil)
+	}
+	registrationAddon := func() *testinghelpers.FakeAgentAddonWithRegistration {
+		return testinghelpers.NewFakeAgentAddonWithRegistration("test", nil, []byte("bootstrap-kubeconfig"))
+	}
+
+	cases := []struct {
+		name      string
+		manager   *AddonManager
+		expectErr bool
+	}{
+		{
+			name:    "defaults are valid",
+			manager: New(plainAddon()),
+		},
+		{
+			name:    "registration options on a registration addon",
+			manager: New(registrationAddon()).WithEnableCSRApproveFunc(approveEverything).WithManifestWorkTTL(time.Hour),
+		},
+		{
+			name:      "empty addon name",
+			manager:   New(testinghelpers.NewFakeAgentAddon("", nil, nil)),
+			expectErr: true,
+		},
+		{
+			name:      "csr approval without the registration interface",
+			manager:   New(plainAddon()).WithEnableCSRApproveFunc(approveEverything),
+			expectErr: true,
+		},
+		{
+			name: "renewal authorizer without csr approval",
+			manager: New(registrationAddon()).WithCSRRenewalSubjectAccessReview(
+				func(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) (bool, string, error) {
+					return true, "", nil
+				}),
+			expectErr: true,
+		},
+		{
+			name:      "work TTL without the registration interface",
+			manager:   New(plainAddon()).WithManifestWorkTTL(time.Hour),
+			expectErr: true,
+		},
+		{
+			name:      "work TTL below the bootstrap window",
+			manager:   New(registrationAddon()).WithManifestWorkTTL(time.Minute),
+			expectErr: true,
+		},
+		{
+			name:      "cluster proxy without the registration interface",
+			manager:   New(plainAddon()).WithClusterProxyIntegration(),
+			expectErr: true,
+		},
+		{
+			name:      "negative max concurrent clusters",
+			manager:   New(plainAddon()).WithMaxConcurrentClusters(-1),
+			expectErr: true,
+		},
+		{
+			name: "config map config combined with a config GVR",
+			manager: func() *AddonManager {
+				agentAddon := plainAddon()
+				agentAddon.Options.AddonConfigGVR = schema.GroupVersionResource{
+					Group: "example.io", Version: "v1", Resource: "configs"}
+				return New(agentAddon).WithConfigMapConfig("addon-config")
+			}(),
+			expectErr: true,
+		},
+		{
+			name:      "config selector without a config GVR",
+			manager:   New(plainAddon()).WithConfigSelector(map[string]string{"app": "test"}),
+			expectErr: true,
+		},
+		{
+			name:      "hub secret inputs without the render interface",
+			manager:   New(plainAddon()).WithHubSecretInputs(map[string]string{"app": "test"}),
+			expectErr: true,
+		},
+		{
+			name:      "work executor without a namespace",
+			manager:   New(plainAddon()).WithWorkExecutor("executor", ""),
+			expectErr: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.manager.Validate()
+			if c.expectErr && err == nil {
+				t.Error("expected a validation error but got none")
+			}
+			if !c.expectErr && err != nil {
+				t.Errorf("expected no validation error but got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package addonmanager
+
+import (
+	"context"
+
+	"github.com/open-cluster-management/addon-framework/pkg/agent"
+	"github.com/openshift/library-go/pkg/controller/controllercmd"
+	"github.com/spf13/pflag"
+)
+
+// ManagerOptions holds the command line configuration of the hub manager of an addon. It is
+// consumed by the manager cobra command an addon binary embeds next to the agent command.
+type ManagerOptions struct {
+	agentAddon agent.AgentAddon
+
+	// ClusterNamespaces scopes this manager instance to the given cluster namespaces, so
+	// management of a large hub can be sharded across instances. Empty means all namespaces.
+	ClusterNamespaces []string
+}
+
+// NewManagerOptions returns a ManagerOptions for the given agent addon
+func NewManagerOptions(agentAddon agent.AgentAddon) *ManagerOptions {
+	return &ManagerOptions{
+		agentAddon: agentAddon,
+	}
+}
+
+// AddFlags registers flags for the hub manager
+func (o *ManagerOptions) AddFlags(fs *pflag.FlagSet) {
+	fs.StringSliceVar(&o.ClusterNamespaces, "cluster-namespaces", o.ClusterNamespaces,
+		"Cluster namespaces this manager instance is scoped to. Manages all namespaces if empty.")
+}
+
+// RunControllerManager starts the hub controllers of the addon
+func (o *ManagerOptions) RunControllerManager(ctx context.Context, controllerContext *controllercmd.ControllerContext) error {
+	return New(o.agentAddon).
+		WithClusterNamespaces(o.ClusterNamespaces...).
+		Run(ctx, controllerContext)
+}
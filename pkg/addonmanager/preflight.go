@@ -0,0 +1,66 @@
+package addonmanager
+
+import (
+	"context"
+	"fmt"
+
+	addonclient "github.com/open-cluster-management/api/client/addon/clientset/versioned"
+	workclient "github.com/open-cluster-management/api/client/work/clientset/versioned"
+	utilerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PreflightClients are the hub clients handed to the preflight checks
+type PreflightClients struct {
+	KubeClient      kubernetes.Interface
+	AddonClient     addonclient.Interface
+	WorkClient      workclient.Interface
+	DiscoveryClient discovery.DiscoveryInterface
+}
+
+// PreflightCheck verifies a prerequisite of the manager on the hub before the controllers
+// start, e.g. that a required CRD is installed or that the manager holds a required
+// permission. A failing check fails Run with the returned error.
+type PreflightCheck func(ctx context.Context, clients PreflightClients) error
+
+// requiredAPICheck returns a preflight check verifying the given group version resource is
+// served by the hub apiserver
+func requiredAPICheck(groupVersion, resource string) PreflightCheck {
+	return func(ctx context.Context, clients PreflightClients) error {
+		resourceList, err := clients.DiscoveryClient.ServerResourcesForGroupVersion(groupVersion)
+		if err != nil {
+			return fmt.Errorf("the api %s is not available on the hub: %v", groupVersion, err)
+		}
+		for _, apiResource := range resourceList.APIResources {
+			if apiResource.Name == resource {
+				return nil
+			}
+		}
+		return fmt.Errorf("the resource %s.%s is not served on the hub, is its CRD installed?", resource, groupVersion)
+	}
+}
+
+// defaultPreflightChecks are the built-in checks every manager runs: the addon and work CRDs
+// the controllers depend on must be installed on the hub
+func defaultPreflightChecks() []PreflightCheck {
+	return []PreflightCheck{
+		requiredAPICheck("addon.open-cluster-management.io/v1alpha1", "managedclusteraddons"),
+		requiredAPICheck("addon.open-cluster-management.io/v1alpha1", "clustermanagementaddons"),
+		requiredAPICheck("cluster.open-cluster-management.io/v1", "managedclusters"),
+		requiredAPICheck("work.open-cluster-management.io/v1", "manifestworks"),
+	}
+}
+
+// runPreflightChecks runs the built-in and the registered preflight checks and aggregates
+// their failures, so a misconfigured hub surfaces as one actionable startup error instead of
+// a stream of per-reconcile errors
+func runPreflightChecks(ctx context.Context, clients PreflightClients, checks []PreflightCheck) error {
+	errs := []error{}
+	for _, check := range append(defaultPreflightChecks(), checks...) {
+		if err := check(ctx, clients); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return utilerrors.NewAggregate(errs)
+}
@@ -0,0 +1,207 @@
+package registration
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/controllers/addonmetrics"
+	clusterlisterv1 "github.com/open-cluster-management/api/client/cluster/listers/cluster/v1"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	certificatesinformers "k8s.io/client-go/informers/certificates/v1"
+	"k8s.io/client-go/kubernetes"
+	certificateslisters "k8s.io/client-go/listers/certificates/v1"
+	"k8s.io/klog/v2"
+
+	clusterinformersv1 "github.com/open-cluster-management/api/client/cluster/informers/externalversions/cluster/v1"
+)
+
+const (
+	// spokeClusterNameLabel is the label on a CSR carrying the name of the managed cluster
+	// the requesting agent runs on
+	spokeClusterNameLabel = "open-cluster-management.io/cluster-name"
+
+	// addonNameLabel is the label on a CSR carrying the name of the addon the requesting
+	// agent belongs to
+	addonNameLabel = "open-cluster-management.io/addon-name"
+)
+
+// CSRApproveCheckFunc checks whether a CSR of the addon agent should be approved automatically
+type CSRApproveCheckFunc func(cluster *clusterv1.ManagedCluster, csr *certificatesv1.CertificateSigningRequest) bool
+
+// CSRRenewalAuthorizer authorizes a CSR of the addon agent before it is auto approved,
+// returning whether the request is allowed and a human-readable reason when it is not. It
+// replaces the built-in subject access review for shops routing authorization through a
+// central policy engine; a denied CSR is left pending for a manual decision.
+type CSRRenewalAuthorizer func(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) (bool, string, error)
+
+// csrApproveController approves the CSRs of the addon agents when the registered approve check
+// accepts them.
+type csrApproveController struct {
+	addonName     string
+	kubeClient    kubernetes.Interface
+	clusterLister clusterlisterv1.ManagedClusterLister
+	csrLister     certificateslisters.CertificateSigningRequestLister
+	approveCheck  CSRApproveCheckFunc
+	authorizer    CSRRenewalAuthorizer
+	eventRecorder events.Recorder
+}
+
+// NewCSRApproveController returns a controller approving the CSRs of the addon agents
+func NewCSRApproveController(
+	addonName string,
+	kubeClient kubernetes.Interface,
+	clusterInformers clusterinformersv1.ManagedClusterInformer,
+	csrInformers certificatesinformers.CertificateSigningRequestInformer,
+	approveCheck CSRApproveCheckFunc,
+	authorizer CSRRenewalAuthorizer,
+	recorder events.Recorder,
+) factory.Controller {
+	c := &csrApproveController{
+		addonName:     addonName,
+		kubeClient:    kubeClient,
+		clusterLister: clusterInformers.Lister(),
+		csrLister:     csrInformers.Lister(),
+		approveCheck:  approveCheck,
+		authorizer:    authorizer,
+		eventRecorder: recorder.WithComponentSuffix("csr-approve-controller"),
+	}
+
+	return factory.New().
+		WithInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				accessor, _ := meta.Accessor(obj)
+				return accessor.GetName()
+			},
+			csrInformers.Informer()).
+		WithSync(addonmetrics.InstrumentSync("CSRApproveController", c.sync)).
+		ToController("AddonCSRApproveController", recorder)
+}
+
+func (c *csrApproveController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	csrName := syncCtx.QueueKey()
+	if csrName == factory.DefaultQueueKey {
+		return nil
+	}
+	klog.V(4).Infof("Reconciling addon csr %q", csrName)
+
+	csr, err := c.csrLister.Get(csrName)
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if csr.Labels[addonNameLabel] != c.addonName {
+		return nil
+	}
+
+	// skip the csr that has been approved or denied already
+	for _, condition := range csr.Status.Conditions {
+		if condition.Type == certificatesv1.CertificateApproved || condition.Type == certificatesv1.CertificateDenied {
+			return nil
+		}
+	}
+
+	clusterName, ok := csr.Labels[spokeClusterNameLabel]
+	if !ok {
+		return nil
+	}
+	cluster, err := c.clusterLister.Get(clusterName)
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if !c.approveCheck(cluster, csr) {
+		return nil
+	}
+
+	allowed, reason, err := c.authorize(ctx, csr)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		c.eventRecorder.Warningf("AddonCSRDenied", "CSR %q of addon %q on cluster %q is not authorized for auto approval: %s", csrName, c.addonName, clusterName, reason)
+		return nil
+	}
+
+	approved := csr.DeepCopy()
+	approved.Status.Conditions = append(approved.Status.Conditions, certificatesv1.CertificateSigningRequestCondition{
+		Type:    certificatesv1.CertificateApproved,
+		Status:  "True",
+		Reason:  "AutoApprovedByAddonManager",
+		Message: fmt.Sprintf("Auto approved by the manager of addon %q", c.addonName),
+	})
+	_, err = c.kubeClient.CertificatesV1().CertificateSigningRequests().UpdateApproval(ctx, approved.Name, approved, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+
+	c.eventRecorder.Eventf("AddonCSRAutoApproved", "CSR %q of addon %q on cluster %q is auto approved", csrName, c.addonName, clusterName)
+	return nil
+}
+
+// authorize runs the registered renewal authorizer over the CSR, falling back to the built-in
+// subject access review asking whether the requesting user may renew the client certificates
+// of the addon
+func (c *csrApproveController) authorize(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) (bool, string, error) {
+	if c.authorizer != nil {
+		return c.authorizer(ctx, csr)
+	}
+
+	extra := map[string]authorizationv1.ExtraValue{}
+	for key, value := range csr.Spec.Extra {
+		extra[key] = authorizationv1.ExtraValue(value)
+	}
+	sar := &authorizationv1.SubjectAccessReview{
+		Spec: authorizationv1.SubjectAccessReviewSpec{
+			User:   csr.Spec.Username,
+			UID:    csr.Spec.UID,
+			Groups: csr.Spec.Groups,
+			Extra:  extra,
+			ResourceAttributes: &authorizationv1.ResourceAttributes{
+				Group:       "register.open-cluster-management.io",
+				Resource:    "managedclusteraddons",
+				Name:        c.addonName,
+				Verb:        "renew",
+				Subresource: "clientcertificates",
+			},
+		},
+	}
+	response, err := c.kubeClient.AuthorizationV1().SubjectAccessReviews().Create(ctx, sar, metav1.CreateOptions{})
+	if err != nil {
+		return false, "", err
+	}
+	return response.Status.Allowed, response.Status.Reason, nil
+}
+
+// CSRRenewalApproveCheck is the built-in approve check accepting the renewal requests issued
+// by the registered agent of the addon itself.
+func CSRRenewalApproveCheck(cluster *clusterv1.ManagedCluster, csr *certificatesv1.CertificateSigningRequest) bool {
+	return csr.Spec.Username == fmt.Sprintf("system:open-cluster-management:cluster:%s:addon:%s", cluster.Name, csr.Labels[addonNameLabel])
+}
+
+// ApproveByClusterClaim returns an approve check accepting the requests from the clusters
+// carrying the given cluster claim with the given value, e.g. only clusters claiming
+// "attested=true". Requests from all other clusters are left for a manual approval.
+func ApproveByClusterClaim(claimName, claimValue string) CSRApproveCheckFunc {
+	return func(cluster *clusterv1.ManagedCluster, csr *certificatesv1.CertificateSigningRequest) bool {
+		for _, claim := range cluster.Status.ClusterClaims {
+			if claim.Name == claimName && claim.Value == claimValue {
+				return true
+			}
+		}
+		return false
+	}
+}
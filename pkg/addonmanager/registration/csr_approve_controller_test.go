@@ -0,0 +1,111 @@
+package registration
+
+import (
+	"context"
+	"testing"
+
+	testinghelpers "github.com/open-cluster-management/addon-framework/pkg/helpers/testing"
+	fakecluster "github.com/open-cluster-management/api/client/cluster/clientset/versioned/fake"
+	clusterinformers "github.com/open-cluster-management/api/client/cluster/informers/externalversions"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"github.com/openshift/library-go/pkg/operator/events"
+	certificatesv1 "k8s.io/api/certificates/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeinformers "k8s.io/client-go/informers"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+)
+
+func newAddonCSR(name string) *certificatesv1.CertificateSigningRequest {
+	return &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			Labels: map[string]string{
+				spokeClusterNameLabel: registrationTestClusterName,
+				addonNameLabel:        registrationTestAddonName,
+			},
+		},
+	}
+}
+
+// newCSRApproveController builds the controller under test directly with fake clients, its
+// listers fed from the informer stores
+func newCSRApproveController(t *testing.T, authorizer CSRRenewalAuthorizer, csr *certificatesv1.CertificateSigningRequest) (*csrApproveController, *fakekube.Clientset) {
+	kubeClient := fakekube.NewSimpleClientset(csr)
+	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(kubeClient, 0)
+	csrInformer := kubeInformerFactory.Certificates().V1().CertificateSigningRequests()
+	if err := csrInformer.Informer().GetStore().Add(csr); err != nil {
+		t.Fatal(err)
+	}
+
+	clusterInformerFactory := clusterinformers.NewSharedInformerFactory(fakecluster.NewSimpleClientset(), 0)
+	clusterInformer := clusterInformerFactory.Cluster().V1().ManagedClusters()
+	cluster := &clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: registrationTestClusterName}}
+	if err := clusterInformer.Informer().GetStore().Add(cluster); err != nil {
+		t.Fatal(err)
+	}
+
+	return &csrApproveController{
+		addonName:     registrationTestAddonName,
+		kubeClient:    kubeClient,
+		clusterLister: clusterInformer.Lister(),
+		csrLister:     csrInformer.Lister(),
+		approveCheck: func(cluster *clusterv1.ManagedCluster, csr *certificatesv1.CertificateSigningRequest) bool {
+			return true
+		},
+		authorizer:    authorizer,
+		eventRecorder: events.NewInMemoryRecorder("test"),
+	}, kubeClient
+}
+
+func TestSyncDenyingAuthorizerLeavesCSRPending(t *testing.T) {
+	csr := newAddonCSR("csr1")
+	denied := false
+	controller, kubeClient := newCSRApproveController(t,
+		func(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) (bool, string, error) {
+			denied = true
+			return false, "denied by policy engine", nil
+		}, csr)
+
+	syncCtx := testinghelpers.NewFakeSyncContext(t, csr.Name)
+	if err := controller.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	if !denied {
+		t.Error("expected the custom authorizer to be consulted")
+	}
+	updated, err := kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Status.Conditions) != 0 {
+		t.Errorf("expected the denied CSR to stay pending, got conditions %v", updated.Status.Conditions)
+	}
+}
+
+func TestSyncAllowingAuthorizerApprovesCSR(t *testing.T) {
+	csr := newAddonCSR("csr1")
+	controller, kubeClient := newCSRApproveController(t,
+		func(ctx context.Context, csr *certificatesv1.CertificateSigningRequest) (bool, string, error) {
+			return true, "", nil
+		}, csr)
+
+	syncCtx := testinghelpers.NewFakeSyncContext(t, csr.Name)
+	if err := controller.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, err := kubeClient.CertificatesV1().CertificateSigningRequests().Get(context.TODO(), csr.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	approved := false
+	for _, condition := range updated.Status.Conditions {
+		if condition.Type == certificatesv1.CertificateApproved {
+			approved = true
+		}
+	}
+	if !approved {
+		t.Errorf("expected the authorized CSR to be approved, got conditions %v", updated.Status.Conditions)
+	}
+}
@@ -0,0 +1,552 @@
+package registration
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/controllers/addonmetrics"
+	"github.com/open-cluster-management/addon-framework/pkg/agent"
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	addonv1alpha1client "github.com/open-cluster-management/api/client/addon/clientset/versioned"
+	addoninformerv1alpha1 "github.com/open-cluster-management/api/client/addon/informers/externalversions/addon/v1alpha1"
+	addonlisterv1alpha1 "github.com/open-cluster-management/api/client/addon/listers/addon/v1alpha1"
+	clusterinformersv1 "github.com/open-cluster-management/api/client/cluster/informers/externalversions/cluster/v1"
+	clusterlisterv1 "github.com/open-cluster-management/api/client/cluster/listers/cluster/v1"
+	workv1client "github.com/open-cluster-management/api/client/work/clientset/versioned"
+	workinformersv1 "github.com/open-cluster-management/api/client/work/informers/externalversions/work/v1"
+	worklisterv1 "github.com/open-cluster-management/api/client/work/listers/work/v1"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+	corev1informers "k8s.io/client-go/informers/core/v1"
+	corev1listers "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/flowcontrol"
+	"k8s.io/klog/v2"
+)
+
+// registrationAgentDeployController deploys the registration bootstrap of the addon agent on
+// the managed cluster: the bootstrap kubeconfig secret and the registration config map read by
+// the certificate manager of the spoke agent.
+type registrationAgentDeployController struct {
+	addonName         string
+	rotationInterval  time.Duration
+	workTTL           time.Duration
+	requeueDelay      time.Duration
+	workExecutor      string
+	workWriteLimiter  flowcontrol.RateLimiter
+	reconcileLock     *helpers.KeyedLock
+	allowedSystemNS   sets.String
+	deletePropagation metav1.DeletionPropagation
+	proxyConfigNS     string
+	proxyConfigName   string
+	configMapLister   corev1listers.ConfigMapLister
+	pruneBootstrap    bool
+	agentAddon        agent.AgentAddonWithRegistration
+	addonClient       addonv1alpha1client.Interface
+	clusterNamespaces sets.String
+	clusterFilter     agent.ManagedClusterFilter
+	workClient        workv1client.Interface
+	addonLister       addonlisterv1alpha1.ManagedClusterAddOnLister
+	clusterLister     clusterlisterv1.ManagedClusterLister
+	workLister        worklisterv1.ManifestWorkLister
+	eventRecorder     events.Recorder
+}
+
+// NewRegistrationAgentDeployController returns a controller syncing the registration manifestwork
+// of the addon agent
+func NewRegistrationAgentDeployController(
+	addonName string,
+	agentAddon agent.AgentAddonWithRegistration,
+	addonClient addonv1alpha1client.Interface,
+	clusterNamespaces []string,
+	clusterFilter agent.ManagedClusterFilter,
+	workClient workv1client.Interface,
+	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
+	clusterInformers clusterinformersv1.ManagedClusterInformer,
+	workInformers workinformersv1.ManifestWorkInformer,
+	rotationInterval time.Duration,
+	pruneBootstrap bool,
+	workTTL time.Duration,
+	requeueDelay time.Duration,
+	workExecutorName string,
+	workExecutorNamespace string,
+	workWriteLimiter flowcontrol.RateLimiter,
+	reconcileLock *helpers.KeyedLock,
+	allowedSystemNamespaces []string,
+	deletePropagation metav1.DeletionPropagation,
+	proxyConfigNamespace string,
+	proxyConfigName string,
+	configMapInformers corev1informers.ConfigMapInformer,
+	recorder events.Recorder,
+) factory.Controller {
+	c := &registrationAgentDeployController{
+		addonName:         addonName,
+		rotationInterval:  rotationInterval,
+		workTTL:           workTTL,
+		pruneBootstrap:    pruneBootstrap,
+		requeueDelay:      requeueDelay,
+		workExecutor:      workExecutorSubject(workExecutorNamespace, workExecutorName),
+		workWriteLimiter:  workWriteLimiter,
+		reconcileLock:     reconcileLock,
+		allowedSystemNS:   sets.NewString(allowedSystemNamespaces...),
+		deletePropagation: deletePropagation,
+		proxyConfigNS:     proxyConfigNamespace,
+		proxyConfigName:   proxyConfigName,
+		agentAddon:        agentAddon,
+		addonClient:       addonClient,
+		clusterNamespaces: sets.NewString(clusterNamespaces...),
+		clusterFilter:     clusterFilter,
+		workClient:        workClient,
+		addonLister:       addonInformers.Lister(),
+		clusterLister:     clusterInformers.Lister(),
+		workLister:        workInformers.Lister(),
+		eventRecorder:     recorder.WithComponentSuffix("registration-agent-deploy-controller"),
+	}
+
+	if configMapInformers != nil {
+		c.configMapLister = configMapInformers.Lister()
+	}
+
+	controllerFactory := factory.New().
+		WithInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				accessor, _ := meta.Accessor(obj)
+				return accessor.GetNamespace() + "/" + accessor.GetName()
+			},
+			addonInformers.Informer()).
+		WithInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				accessor, _ := meta.Accessor(obj)
+				return accessor.GetName() + "/" + addonName
+			},
+			clusterInformers.Informer()).
+		WithSync(addonmetrics.InstrumentSync("RegistrationAgentDeployController", func(ctx context.Context, syncCtx factory.SyncContext) error {
+			if c.reconcileLock != nil {
+				// serialize with the other controllers acting on the same cluster
+				if clusterName, _, err := cache.SplitMetaNamespaceKey(syncCtx.QueueKey()); err == nil && len(clusterName) > 0 {
+					c.reconcileLock.Lock(clusterName)
+					defer c.reconcileLock.Unlock(clusterName)
+				}
+			}
+			// back off gently on transient API errors instead of the tight per-item retry
+			return helpers.RequeueOnTransient(syncCtx, c.sync(ctx, syncCtx), c.requeueDelay)
+		}))
+
+	if len(proxyConfigNamespace) > 0 && len(proxyConfigName) > 0 && configMapInformers != nil {
+		// re-ship every bootstrap when the proxy endpoint changes
+		controllerFactory = controllerFactory.WithFilteredEventsInformersQueueKeyFunc(
+			func(obj runtime.Object) string {
+				return factory.DefaultQueueKey
+			},
+			func(obj interface{}) bool {
+				if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+					obj = tombstone.Obj
+				}
+				accessor, err := meta.Accessor(obj)
+				if err != nil {
+					return false
+				}
+				return accessor.GetNamespace() == proxyConfigNamespace && accessor.GetName() == proxyConfigName
+			},
+			configMapInformers.Informer())
+	}
+
+	if c.rotationInterval > 0 {
+		// re-invoke AgentBootstrapKubeConfig on a schedule so a rotated bootstrap
+		// credential is re-shipped without any event on the hub; agents that already
+		// bootstrapped keep using their hub kubeconfig and are not affected
+		controllerFactory = controllerFactory.ResyncEvery(c.rotationInterval)
+	}
+
+	return controllerFactory.ToController("RegistrationAgentDeployController", recorder)
+}
+
+func (c *registrationAgentDeployController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
+	key := syncCtx.QueueKey()
+	klog.V(4).Infof("Reconciling addon registration deploy %q", key)
+
+	if key == factory.DefaultQueueKey {
+		// scheduled rotation resync: requeue every addon install
+		addons, err := c.addonLister.List(labels.Everything())
+		if err != nil {
+			return err
+		}
+		for _, addon := range addons {
+			if addon.Name != c.addonName {
+				continue
+			}
+			syncCtx.Queue().Add(addon.Namespace + "/" + addon.Name)
+		}
+		return nil
+	}
+
+	clusterName, addonName, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		// ignore addon whose key is not in format: namespace/name
+		return nil
+	}
+	if addonName != c.addonName {
+		return nil
+	}
+	if c.clusterNamespaces.Len() > 0 && !c.clusterNamespaces.Has(clusterName) {
+		// the cluster namespace belongs to another manager shard
+		return nil
+	}
+
+	addon, err := c.addonLister.ManagedClusterAddOns(clusterName).Get(addonName)
+	switch {
+	case errors.IsNotFound(err):
+		return c.removeWork(ctx, clusterName)
+	case err != nil:
+		return err
+	}
+
+	if helpers.AddonPaused(addon) {
+		// reconciliation is paused; leave the registration work untouched
+		return nil
+	}
+
+	if c.pruneBootstrap && meta.IsStatusConditionTrue(addon.Status.Conditions, constants.RegistrationBootstrappedCondition) {
+		// the agent has bootstrapped and holds a valid hub client config; remove the
+		// work shipping the bootstrap credential to shrink its exposure window
+		return c.removeWork(ctx, clusterName)
+	}
+
+	if expired, err := c.enforceWorkTTL(ctx, syncCtx, addon); err != nil || expired {
+		return err
+	}
+
+	cluster, err := c.clusterLister.Get(clusterName)
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if !cluster.DeletionTimestamp.IsZero() {
+		return c.removeWork(ctx, clusterName)
+	}
+
+	if c.clusterFilter != nil && !c.clusterFilter(cluster) {
+		// the cluster is excluded by the filter; remove the bootstrap shipped while it
+		// was still included
+		return c.removeWork(ctx, clusterName)
+	}
+
+	configs := c.registrationConfigs(cluster)
+	for _, config := range configs {
+		// guard the registration secrets against landing in a protected system namespace,
+		// mirroring the same check on the deploy path
+		if helpers.IsProtectedNamespace(config.InstallNamespace) && !c.allowedSystemNS.Has(config.InstallNamespace) {
+			return fmt.Errorf("the install namespace %q resolved for cluster %q is a protected system namespace; allowlist it with WithSystemNamespaceAllowlist if this is intended", config.InstallNamespace, clusterName)
+		}
+	}
+
+	kubeconfig, err := c.agentAddon.AgentBootstrapKubeConfig(cluster)
+	if err != nil {
+		return err
+	}
+	kubeconfig, err = c.rewriteForClusterProxy(kubeconfig)
+	if err != nil {
+		return err
+	}
+	misconfigured, err := c.checkBootstrapKubeconfig(ctx, addon, configs, kubeconfig)
+	if err != nil {
+		return err
+	}
+	if misconfigured {
+		// do not ship a registration work the agent can never bootstrap from
+		return nil
+	}
+
+	work, err := c.buildRegistrationWork(addon, configs, kubeconfig)
+	if err != nil {
+		return err
+	}
+
+	if _, _, err = helpers.ApplyWork(ctx, c.workClient, c.workLister, c.eventRecorder, c.workWriteLimiter, work); err != nil {
+		return err
+	}
+
+	cond := metav1.Condition{
+		Type:    constants.RegistrationAppliedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "RegistrationWorkApplied",
+		Message: fmt.Sprintf("The registration manifestwork %s/%s is applied", work.Namespace, work.Name),
+	}
+	if _, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond)); err != nil {
+		return err
+	}
+
+	return c.updateRegistrationAnnotations(ctx, addon, configs[0])
+}
+
+// RegistrationMisconfigured is the condition type reporting that the registration of the addon
+// is enabled but misconfigured, e.g. an empty bootstrap kubeconfig without an external
+// bootstrap opt-out.
+const RegistrationMisconfigured = "RegistrationMisconfigured"
+
+// checkBootstrapKubeconfig reports on the addon whether the bootstrap kubeconfig of the agent
+// is usable. Empty bootstrap bytes used to skip the registration work silently, leaving an
+// agent that can never register; they are now a configuration error unless every config opts
+// out with ExternalBootstrap. It returns whether the registration is misconfigured.
+func (c *registrationAgentDeployController) checkBootstrapKubeconfig(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn, configs []agent.RegistrationConfig, kubeconfig []byte) (bool, error) {
+	needsBootstrap := false
+	for _, config := range configs {
+		if !config.ExternalBootstrap {
+			needsBootstrap = true
+		}
+	}
+	misconfigured := needsBootstrap && len(kubeconfig) == 0
+
+	cond := metav1.Condition{
+		Type:    RegistrationMisconfigured,
+		Status:  metav1.ConditionFalse,
+		Reason:  "RegistrationConfigured",
+		Message: "The registration of the addon is configured",
+	}
+	if misconfigured {
+		cond = metav1.Condition{
+			Type:    RegistrationMisconfigured,
+			Status:  metav1.ConditionTrue,
+			Reason:  "EmptyBootstrapKubeConfig",
+			Message: "AgentBootstrapKubeConfig returned no bytes; set ExternalBootstrap on the registration config if the agent brings its own bootstrap credential",
+		}
+	}
+
+	if _, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond)); err != nil {
+		return false, err
+	}
+	return misconfigured, nil
+}
+
+// enforceWorkTTL enforces the time-based expiry of the registration work configured with
+// WithManifestWorkTTL, as a purely time-based complement to the bootstrapped-condition pruning
+// that does not depend on the spoke reporting anything. The expiry is anchored to the creation
+// time of the addon rather than of the work itself, so it stays stable when the work is
+// recreated and a deleted bootstrap credential is not re-shipped in a loop. It returns whether
+// the work has expired and must not be applied again.
+func (c *registrationAgentDeployController) enforceWorkTTL(ctx context.Context, syncCtx factory.SyncContext, addon *addonapiv1alpha1.ManagedClusterAddOn) (bool, error) {
+	if c.workTTL <= 0 {
+		return false, nil
+	}
+
+	remaining := time.Until(addon.CreationTimestamp.Add(c.workTTL))
+	if remaining > 0 {
+		// come back right past the deadline; no hub event triggers the expiry otherwise
+		syncCtx.Queue().AddAfter(addon.Namespace+"/"+addon.Name, remaining+time.Second)
+		return false, nil
+	}
+	return true, c.removeWork(ctx, addon.Namespace)
+}
+
+// rewriteForClusterProxy rewrites the bootstrap kubeconfig to the endpoint published by the
+// cluster-proxy config map when the integration is enabled, so agents on network-isolated
+// clusters reach the hub through the proxy. It degrades to the direct endpoint while no proxy
+// endpoint is published, and the bootstrap is re-shipped once one appears.
+func (c *registrationAgentDeployController) rewriteForClusterProxy(kubeconfig []byte) ([]byte, error) {
+	if len(c.proxyConfigNS) == 0 || len(c.proxyConfigName) == 0 || c.configMapLister == nil || len(kubeconfig) == 0 {
+		return kubeconfig, nil
+	}
+
+	configMap, err := c.configMapLister.ConfigMaps(c.proxyConfigNS).Get(c.proxyConfigName)
+	switch {
+	case errors.IsNotFound(err):
+		return kubeconfig, nil
+	case err != nil:
+		return nil, err
+	}
+
+	proxyURL := configMap.Data[constants.ProxyConfigURLKey]
+	if len(proxyURL) == 0 {
+		return kubeconfig, nil
+	}
+	return helpers.RewriteKubeConfigServer(kubeconfig, proxyURL, []byte(configMap.Data[constants.ProxyConfigCAKey]))
+}
+
+// registrationConfigs resolves the registration configs of the agent for the given cluster,
+// one per signer for an addon registering with multiple signers
+func (c *registrationAgentDeployController) registrationConfigs(cluster *clusterv1.ManagedCluster) []agent.RegistrationConfig {
+	options := c.agentAddon.GetAgentAddonOptions()
+
+	if multiSignerAgentAddon, ok := c.agentAddon.(agent.AgentAddonWithRegistrations); ok {
+		configs := []agent.RegistrationConfig{}
+		for _, config := range multiSignerAgentAddon.RegistrationConfigs(cluster) {
+			configs = append(configs, config.WithDefaults(options, cluster))
+		}
+		if len(configs) > 0 {
+			return configs
+		}
+	}
+
+	return []agent.RegistrationConfig{c.agentAddon.RegistrationConfig(cluster).WithDefaults(options, cluster)}
+}
+
+// updateRegistrationAnnotations surfaces the resolved registration details on the addon so
+// tooling can discover the signer and the secret names without reading the registration work.
+// TODO: populate a typed status field instead once the vendored addon API grows one.
+func (c *registrationAgentDeployController) updateRegistrationAnnotations(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn, config agent.RegistrationConfig) error {
+	annotations := map[string]string{
+		constants.RegistrationSignerAnnotation:              config.SignerName,
+		constants.RegistrationInstallNamespaceAnnotation:    config.InstallNamespace,
+		constants.RegistrationBootstrapSecretAnnotation:     config.BootstrapSecret,
+		constants.RegistrationHubKubeconfigSecretAnnotation: config.HubKubeconfigSecret,
+	}
+
+	changed := false
+	for key, value := range annotations {
+		if addon.Annotations[key] != value {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	updated := addon.DeepCopy()
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	for key, value := range annotations {
+		updated.Annotations[key] = value
+	}
+	_, err := c.addonClient.AddonV1alpha1().ManagedClusterAddOns(updated.Namespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// buildRegistrationWork builds the manifestwork deploying the bootstrap kubeconfig secret and
+// the registration config map of the agent in its install namespace
+func (c *registrationAgentDeployController) buildRegistrationWork(addon *addonapiv1alpha1.ManagedClusterAddOn, configs []agent.RegistrationConfig, kubeconfig []byte) (*workapiv1.ManifestWork, error) {
+	objects := []runtime.Object{}
+	shippedSecrets := sets.NewString()
+	for index, config := range configs {
+		if !config.ExternalBootstrap && !shippedSecrets.Has(config.BootstrapSecret) {
+			shippedSecrets.Insert(config.BootstrapSecret)
+			objects = append(objects, &corev1.Secret{
+				TypeMeta: metav1.TypeMeta{
+					APIVersion: "v1",
+					Kind:       "Secret",
+				},
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      config.BootstrapSecret,
+					Namespace: config.InstallNamespace,
+				},
+				Data: map[string][]byte{
+					"kubeconfig": kubeconfig,
+				},
+			})
+		}
+
+		objects = append(objects, &corev1.ConfigMap{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "ConfigMap",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      constants.SignerRegistrationConfigMapName(config.AddonName, index),
+				Namespace: config.InstallNamespace,
+				Labels: map[string]string{
+					constants.AddonLabel: config.AddonName,
+				},
+			},
+			Data: map[string]string{
+				constants.RegistrationConfigAddonNameKey:           config.AddonName,
+				constants.RegistrationConfigSignerNameKey:          config.SignerName,
+				constants.RegistrationConfigBootstrapSecretKey:     config.BootstrapSecret,
+				constants.RegistrationConfigHubKubeconfigSecretKey: config.HubKubeconfigSecret,
+				constants.RegistrationConfigExpirationSecondsKey:   strconv.FormatInt(int64(config.ExpirationSeconds), 10),
+				constants.RegistrationConfigSANsKey:                strings.Join(config.SANs, ","),
+			},
+		})
+	}
+
+	manifests := []workapiv1.Manifest{}
+	for _, object := range objects {
+		manifest, err := helpers.NewManifest(object)
+		if err != nil {
+			return nil, err
+		}
+		manifests = append(manifests, manifest)
+	}
+
+	annotations := map[string]string{}
+	if len(c.workExecutor) > 0 {
+		annotations[constants.WorkExecutorAnnotation] = c.workExecutor
+	}
+	if c.workTTL > 0 {
+		annotations[constants.RegistrationWorkExpiryAnnotation] = addon.CreationTimestamp.Add(c.workTTL).UTC().Format(time.RFC3339)
+	}
+
+	return &workapiv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        constants.RegistrationWorkName(c.addonName),
+			Namespace:   addon.Namespace,
+			Annotations: annotations,
+			Labels: map[string]string{
+				constants.AddonWorkLabel:     c.addonName,
+				constants.AddonWorkTypeLabel: constants.RegistrationWorkType,
+			},
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(addon, addonapiv1alpha1.GroupVersion.WithKind("ManagedClusterAddOn")),
+			},
+		},
+		Spec: workapiv1.ManifestWorkSpec{
+			Workload: workapiv1.ManifestsTemplate{
+				Manifests: manifests,
+			},
+		},
+	}, nil
+}
+
+// workExecutorSubject renders the "<namespace>/<name>" executor subject of the configured
+// work executor service account, or an empty string when none is configured
+func workExecutorSubject(namespace, name string) string {
+	if len(namespace) == 0 || len(name) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s", namespace, name)
+}
+
+// removeWork deletes the registration manifestworks of the addon in the given cluster
+// namespace. The works are selected by the labels they are created with rather than by the
+// name pattern, so the cleanup is explicit about what it owns; the well-known name is kept as
+// a fallback for works shipped by older managers that did not carry the labels yet.
+func (c *registrationAgentDeployController) removeWork(ctx context.Context, clusterName string) error {
+	works, err := c.workLister.ManifestWorks(clusterName).List(
+		labels.SelectorFromSet(labels.Set{constants.AddonWorkLabel: c.addonName, constants.AddonWorkTypeLabel: constants.RegistrationWorkType}))
+	if err != nil {
+		return err
+	}
+
+	names := sets.NewString(constants.RegistrationWorkName(c.addonName))
+	for _, work := range works {
+		names.Insert(work.Name)
+	}
+
+	for _, workName := range names.List() {
+		err := c.workClient.WorkV1().ManifestWorks(clusterName).Delete(ctx, workName, helpers.DeleteOptionsWithPropagation(c.deletePropagation))
+		switch {
+		case errors.IsNotFound(err):
+			continue
+		case err != nil:
+			return err
+		}
+		c.eventRecorder.Eventf("AddonRegistrationWorkDeleted", "Deleted manifestwork %s/%s", clusterName, workName)
+	}
+	return nil
+}
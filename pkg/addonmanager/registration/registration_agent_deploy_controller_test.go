@@ -0,0 +1,202 @@
+package registration
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	testinghelpers "github.com/open-cluster-management/addon-framework/pkg/helpers/testing"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	fakeaddon "github.com/open-cluster-management/api/client/addon/clientset/versioned/fake"
+	addoninformers "github.com/open-cluster-management/api/client/addon/informers/externalversions"
+	fakecluster "github.com/open-cluster-management/api/client/cluster/clientset/versioned/fake"
+	clusterinformers "github.com/open-cluster-management/api/client/cluster/informers/externalversions"
+	fakework "github.com/open-cluster-management/api/client/work/clientset/versioned/fake"
+	workinformers "github.com/open-cluster-management/api/client/work/informers/externalversions"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"github.com/openshift/library-go/pkg/operator/events"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
+)
+
+const (
+	registrationTestAddonName   = "test"
+	registrationTestClusterName = "cluster1"
+)
+
+func newRegistrationAddon(creationTime time.Time) *addonapiv1alpha1.ManagedClusterAddOn {
+	return &addonapiv1alpha1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              registrationTestAddonName,
+			Namespace:         registrationTestClusterName,
+			UID:               "addon-uid",
+			CreationTimestamp: metav1.NewTime(creationTime),
+		},
+	}
+}
+
+func newRegistrationWork(name string, labeled bool) *workapiv1.ManifestWork {
+	work := &workapiv1.ManifestWork{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: registrationTestClusterName,
+		},
+	}
+	if labeled {
+		work.Labels = map[string]string{
+			constants.AddonWorkLabel:     registrationTestAddonName,
+			constants.AddonWorkTypeLabel: constants.RegistrationWorkType,
+		}
+	}
+	return work
+}
+
+// newRegistrationController builds the controller under test directly with fake clients, its
+// listers fed from the informer stores
+func newRegistrationController(
+	t *testing.T,
+	addon *addonapiv1alpha1.ManagedClusterAddOn,
+	cluster *clusterv1.ManagedCluster,
+	works ...*workapiv1.ManifestWork,
+) (*registrationAgentDeployController, *fakework.Clientset) {
+	addonObjects := []runtime.Object{}
+	if addon != nil {
+		addonObjects = append(addonObjects, addon)
+	}
+	addonClient := fakeaddon.NewSimpleClientset(addonObjects...)
+	addonInformerFactory := addoninformers.NewSharedInformerFactory(addonClient, 0)
+	addonInformer := addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns()
+	if addon != nil {
+		if err := addonInformer.Informer().GetStore().Add(addon); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	clusterInformerFactory := clusterinformers.NewSharedInformerFactory(fakecluster.NewSimpleClientset(), 0)
+	clusterInformer := clusterInformerFactory.Cluster().V1().ManagedClusters()
+	if cluster != nil {
+		if err := clusterInformer.Informer().GetStore().Add(cluster); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	workObjects := []runtime.Object{}
+	for _, work := range works {
+		workObjects = append(workObjects, work)
+	}
+	workClient := fakework.NewSimpleClientset(workObjects...)
+	workInformerFactory := workinformers.NewSharedInformerFactory(workClient, 0)
+	workInformer := workInformerFactory.Work().V1().ManifestWorks()
+	for _, work := range works {
+		if err := workInformer.Informer().GetStore().Add(work); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &registrationAgentDeployController{
+		addonName: registrationTestAddonName,
+		agentAddon: testinghelpers.NewFakeAgentAddonWithRegistration(
+			registrationTestAddonName, nil, []byte("bootstrap-kubeconfig")),
+		addonClient:       addonClient,
+		clusterNamespaces: sets.NewString(),
+		workClient:        workClient,
+		addonLister:       addonInformer.Lister(),
+		clusterLister:     clusterInformer.Lister(),
+		workLister:        workInformer.Lister(),
+		allowedSystemNS:   sets.NewString(),
+		deletePropagation: metav1.DeletePropagationBackground,
+		eventRecorder:     events.NewInMemoryRecorder("test"),
+	}, workClient
+}
+
+func syncRegistrationController(t *testing.T, controller *registrationAgentDeployController) error {
+	t.Helper()
+	syncCtx := testinghelpers.NewFakeSyncContext(t, registrationTestClusterName+"/"+registrationTestAddonName)
+	return controller.sync(context.TODO(), syncCtx)
+}
+
+func TestSyncReconcilesStaleOwnerReference(t *testing.T) {
+	addon := newRegistrationAddon(time.Now())
+	existing := newRegistrationWork(constants.RegistrationWorkName(registrationTestAddonName), true)
+	existing.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: "addon.open-cluster-management.io/v1alpha1",
+		Kind:       "ClusterManagementAddOn",
+		Name:       "stale-owner",
+		UID:        "stale-uid",
+	}}
+	controller, workClient := newRegistrationController(t, addon,
+		&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: registrationTestClusterName}}, existing)
+
+	if err := syncRegistrationController(t, controller); err != nil {
+		t.Fatal(err)
+	}
+
+	work, err := workClient.WorkV1().ManifestWorks(registrationTestClusterName).Get(
+		context.TODO(), existing.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(work.OwnerReferences) != 1 {
+		t.Fatalf("expected a single owner reference, got %v", work.OwnerReferences)
+	}
+	owner := work.OwnerReferences[0]
+	if owner.Kind != "ManagedClusterAddOn" || owner.Name != registrationTestAddonName || owner.UID != addon.UID {
+		t.Errorf("expected the stale owner reference to be reconciled to the addon, got %+v", owner)
+	}
+}
+
+func TestSyncWorkTTLNotExpired(t *testing.T) {
+	controller, workClient := newRegistrationController(t, newRegistrationAddon(time.Now()),
+		&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: registrationTestClusterName}})
+	controller.workTTL = time.Hour
+
+	if err := syncRegistrationController(t, controller); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := workClient.WorkV1().ManifestWorks(registrationTestClusterName).Get(
+		context.TODO(), constants.RegistrationWorkName(registrationTestAddonName), metav1.GetOptions{}); err != nil {
+		t.Errorf("expected the registration work to be applied within the TTL: %v", err)
+	}
+}
+
+func TestSyncWorkTTLExpired(t *testing.T) {
+	existing := newRegistrationWork(constants.RegistrationWorkName(registrationTestAddonName), true)
+	controller, workClient := newRegistrationController(t, newRegistrationAddon(time.Now().Add(-2*time.Hour)),
+		&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: registrationTestClusterName}}, existing)
+	controller.workTTL = time.Hour
+
+	if err := syncRegistrationController(t, controller); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := workClient.WorkV1().ManifestWorks(registrationTestClusterName).Get(
+		context.TODO(), existing.Name, metav1.GetOptions{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("expected the registration work to be removed past the TTL, got %v", err)
+	}
+}
+
+func TestSyncRemovesWorksOnAddonDeletion(t *testing.T) {
+	// a labeled work and a legacy one carrying only the well-known name: both must go
+	labeled := newRegistrationWork(constants.RegistrationWorkName(registrationTestAddonName)+"-extra", true)
+	legacy := newRegistrationWork(constants.RegistrationWorkName(registrationTestAddonName), false)
+	controller, workClient := newRegistrationController(t, nil,
+		&clusterv1.ManagedCluster{ObjectMeta: metav1.ObjectMeta{Name: registrationTestClusterName}}, labeled, legacy)
+
+	if err := syncRegistrationController(t, controller); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{labeled.Name, legacy.Name} {
+		_, err := workClient.WorkV1().ManifestWorks(registrationTestClusterName).Get(
+			context.TODO(), name, metav1.GetOptions{})
+		if !apierrors.IsNotFound(err) {
+			t.Errorf("expected manifestwork %q to be removed with the addon, got %v", name, err)
+		}
+	}
+}
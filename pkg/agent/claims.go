@@ -0,0 +1,46 @@
+package agent
+
+import (
+	"fmt"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// RequiredClaimAnnotation marks an agent manifest as conditional on a cluster claim. The
+// value is "<claim name>" or "<claim name>=<claim value>"; the deploy controller drops the
+// manifest on clusters that do not report the claim, or report it with a different value.
+// Use it to vary the manifests per cluster declaratively, e.g. ship a NetworkPolicy only
+// where a claim reports a CNI that supports it, instead of branching in AgentManifests.
+const RequiredClaimAnnotation = "addon.open-cluster-management.io/required-claim"
+
+// RequireClaim annotates the manifest so it is only deployed on clusters reporting the given
+// claim with the given value. An empty value only requires the claim to be reported at all.
+func RequireClaim(object runtime.Object, name, value string) {
+	accessor, err := meta.Accessor(object)
+	if err != nil {
+		return
+	}
+	annotations := accessor.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	requirement := name
+	if len(value) > 0 {
+		requirement = fmt.Sprintf("%s=%s", name, value)
+	}
+	annotations[RequiredClaimAnnotation] = requirement
+	accessor.SetAnnotations(annotations)
+}
+
+// ClusterClaimValue returns the value of the named claim reported by the managed cluster, and
+// whether the claim is reported at all
+func ClusterClaimValue(cluster *clusterv1.ManagedCluster, name string) (string, bool) {
+	for _, claim := range cluster.Status.ClusterClaims {
+		if claim.Name == name {
+			return claim.Value, true
+		}
+	}
+	return "", false
+}
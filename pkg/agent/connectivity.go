@@ -0,0 +1,30 @@
+package agent
+
+import (
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// AgentAddonWithConnectivity is an AgentAddon whose manifests need the resolved connectivity
+// details of the hub, e.g. to route the agent traffic through a cluster-proxy. The deploy
+// controller calls AgentManifestsWithConnectivity instead of AgentManifests when this
+// interface is implemented.
+type AgentAddonWithConnectivity interface {
+	AgentAddon
+
+	// AgentManifestsWithConnectivity is AgentManifests with the connectivity details the
+	// manager resolved for the managed cluster. The proxy URL is empty when no
+	// cluster-proxy is configured, in which case the agent should talk to the hub
+	// directly.
+	AgentManifestsWithConnectivity(cluster *clusterv1.ManagedCluster, config runtime.Object, connectivity ConnectivityInfo) ([]runtime.Object, error)
+}
+
+// ConnectivityInfo holds the connectivity details of the hub resolved for a managed cluster
+type ConnectivityInfo struct {
+	// HubAPIServerURL is the URL of the hub API server the manager talks to
+	HubAPIServerURL string
+
+	// ProxyURL is the endpoint of the cluster-proxy to route agent traffic through, or
+	// empty when no proxy is configured
+	ProxyURL string
+}
@@ -0,0 +1,16 @@
+package agent
+
+// FailurePolicy declares how failures of individual agent manifests reflect in the health of
+// the addon.
+type FailurePolicy string
+
+const (
+	// FailurePolicyAll degrades the addon when any manifest of the deploy works fails. This
+	// is the default, on the grounds that a partially applied agent is not healthy unless
+	// the author says otherwise.
+	FailurePolicyAll FailurePolicy = "All"
+
+	// FailurePolicyBestEffort degrades the addon only when a manifest the author flagged as
+	// critical fails; failures of the remaining manifests are tolerated.
+	FailurePolicyBestEffort FailurePolicy = "BestEffort"
+)
@@ -0,0 +1,12 @@
+package agent
+
+import (
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+)
+
+// ManagedClusterFilter decides whether the addon manages the given cluster. The hub controllers
+// apply the same filter uniformly, so an excluded cluster is skipped everywhere rather than
+// being half-managed: nothing is deployed to it and no registration bootstrap is shipped. When
+// a managed cluster transitions from included to excluded, the controllers clean up the works
+// they deployed to it.
+type ManagedClusterFilter func(cluster *clusterv1.ManagedCluster) bool
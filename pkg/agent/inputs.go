@@ -0,0 +1,41 @@
+package agent
+
+import (
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ManifestInputs bundles the optional render inputs of the single-input render interfaces, for
+// addons whose rendering needs more than one of them. Every field carries its zero value when
+// the corresponding input is not available, e.g. the hub secrets when no selector is configured
+// on the manager or the deployed digest on a fresh install.
+type ManifestInputs struct {
+	// Connectivity is the connectivity details of the hub, see AgentAddonWithConnectivity.
+	Connectivity ConnectivityInfo
+
+	// DeployedDigest is the digest of the agent manifests currently deployed on the managed
+	// cluster, see AgentAddonWithDeployedDigest.
+	DeployedDigest string
+
+	// HubSecrets is the matching secrets of the cluster namespace on the hub, sorted by
+	// name, see AgentAddonWithHubSecrets. Implementations must not log the secret contents.
+	HubSecrets []*corev1.Secret
+
+	// InstalledAddons is the sorted names of the ManagedClusterAddOns present in the cluster
+	// namespace, see AgentAddonWithInstalledAddons.
+	InstalledAddons []string
+}
+
+// AgentAddonWithManifestInputs is an AgentAddon whose manifest rendering receives every
+// optional render input in one call. Implement it instead of combining several of the
+// single-input interfaces, whose render calls would otherwise shadow each other; the deploy
+// controller rejects such combinations. An addon that additionally declares manifest
+// identities stamps the manifest ID annotation on its objects directly instead of implementing
+// AgentAddonWithManifestIDs.
+type AgentAddonWithManifestInputs interface {
+	AgentAddon
+
+	// AgentManifestsWithInputs is AgentManifests with the optional render inputs.
+	AgentManifestsWithInputs(cluster *clusterv1.ManagedCluster, config runtime.Object, inputs ManifestInputs) ([]runtime.Object, error)
+}
@@ -0,0 +1,21 @@
+package agent
+
+import (
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// AgentAddonWithInstalledAddons is an AgentAddon whose manifest rendering additionally
+// receives the names of the other ManagedClusterAddOns installed on the same cluster, for
+// cross-addon coordination like port-conflict avoidance or composition. The deploy controller
+// calls AgentManifestsWithInstalledAddons instead of AgentManifests when this interface is
+// implemented.
+type AgentAddonWithInstalledAddons interface {
+	AgentAddon
+
+	// AgentManifestsWithInstalledAddons is AgentManifests with the sorted names of all
+	// ManagedClusterAddOns present in the cluster namespace, including this addon itself.
+	// The list reflects the informer cache of the manager and may lag behind the apiserver
+	// briefly; a change to it requeues the deploy.
+	AgentManifestsWithInstalledAddons(cluster *clusterv1.ManagedCluster, config runtime.Object, installedAddons []string) ([]runtime.Object, error)
+}
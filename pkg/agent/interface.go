@@ -0,0 +1,185 @@
+package agent
+
+import (
+	"fmt"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// AgentAddon is the interface for an addon agent deployed on the managed cluster. An addon
+// author implements this interface and registers it to the addon manager, which deploys and
+// maintains the returned manifests on each managed cluster.
+type AgentAddon interface {
+	// AgentManifests returns a list of manifest resources to be deployed on the managed cluster
+	// for this addon. The config is the addon configuration resource referenced by the
+	// addOnConfiguration coordinates of the ManagedClusterAddOn, and is nil if no configuration
+	// is referenced.
+	AgentManifests(cluster *clusterv1.ManagedCluster, config runtime.Object) ([]runtime.Object, error)
+
+	// GetAgentAddonOptions returns the agent options for advanced agent customization.
+	GetAgentAddonOptions() AgentAddonOptions
+}
+
+// AgentAddonWithDeployedDigest is an AgentAddon that renders different manifests for an agent
+// upgrade than for a fresh install, e.g. shipping a migration Job only when a prior version is
+// already deployed. The deploy controller calls AgentManifestsWithDeployedDigest instead of
+// AgentManifests when this interface is implemented.
+type AgentAddonWithDeployedDigest interface {
+	AgentAddon
+
+	// AgentManifestsWithDeployedDigest is AgentManifests with the digest of the agent
+	// manifests currently deployed on the managed cluster. The digest is empty on a fresh
+	// install where no deploy manifestwork exists yet.
+	AgentManifestsWithDeployedDigest(cluster *clusterv1.ManagedCluster, config runtime.Object, deployedDigest string) ([]runtime.Object, error)
+}
+
+// AgentAddonWithRenderInputs is an AgentAddon that declares which parts of the managed cluster
+// its manifest rendering depends on. The deploy controller fingerprints the declared inputs
+// and only re-renders when the fingerprint changes, so a label-driven addon reliably picks up
+// the label changes it cares about without re-rendering on every unrelated cluster event.
+type AgentAddonWithRenderInputs interface {
+	AgentAddon
+
+	// RenderInputs returns the cluster labels and claims the manifest rendering depends on.
+	RenderInputs() RenderInputs
+}
+
+// RenderInputs are the parts of a managed cluster a manifest rendering depends on
+type RenderInputs struct {
+	// Labels are the keys of the cluster labels the rendering depends on
+	Labels []string
+
+	// Claims are the names of the cluster claims the rendering depends on
+	Claims []string
+}
+
+// AgentAddonWithRegistration is an AgentAddon whose agent also registers to the hub with a
+// client certificate. The addon manager deploys the bootstrap kubeconfig and the registration
+// config on the managed cluster, and the certificate manager of the spoke agent maintains the
+// hub kubeconfig secret from them.
+type AgentAddonWithRegistration interface {
+	AgentAddon
+
+	// RegistrationConfig returns how the agent of the addon registers to the hub for the
+	// given cluster.
+	RegistrationConfig(cluster *clusterv1.ManagedCluster) RegistrationConfig
+
+	// AgentBootstrapKubeConfig returns the kubeconfig bytes used by the agent to bootstrap
+	// its registration to the hub.
+	AgentBootstrapKubeConfig(cluster *clusterv1.ManagedCluster) ([]byte, error)
+}
+
+// AgentAddonWithRegistrations is an AgentAddonWithRegistration whose agent registers with
+// multiple signers, e.g. a client-auth certificate to talk to the hub and a serving
+// certificate for its webhook. One registration config is shipped per signer, and the CSR
+// approve check on the hub can branch on the signer name of each CSR. When this interface is
+// implemented RegistrationConfigs takes precedence over RegistrationConfig.
+type AgentAddonWithRegistrations interface {
+	AgentAddonWithRegistration
+
+	// RegistrationConfigs returns one registration config per signer the agent of the addon
+	// registers with for the given cluster. The secret names of the configs must not
+	// collide; set them explicitly since the defaults are derived from the addon name only.
+	RegistrationConfigs(cluster *clusterv1.ManagedCluster) []RegistrationConfig
+}
+
+// RegistrationConfig holds how the agent of an addon registers to the hub.
+type RegistrationConfig struct {
+	// AddonName is the name of the addon. Defaults to the addon name of the agent options.
+	AddonName string
+
+	// InstallNamespace is the namespace on the managed cluster in which the registration
+	// secrets are maintained. Defaults to the install namespace of the agent options.
+	InstallNamespace string
+
+	// SignerName is the name of the signer signing the client certificate of the agent.
+	SignerName string
+
+	// BootstrapSecret is the name of the secret in the install namespace carrying the
+	// bootstrap kubeconfig of the agent. Defaults to "<addon name>-bootstrap-kubeconfig".
+	// Set it explicitly when multiple addons share an install namespace.
+	BootstrapSecret string
+
+	// HubKubeconfigSecret is the name of the secret in the install namespace in which the
+	// certificate manager on the managed cluster maintains the hub kubeconfig of the agent.
+	// Defaults to "<addon name>-hub-kubeconfig". Set it explicitly when multiple addons
+	// share an install namespace.
+	HubKubeconfigSecret string
+
+	// ExternalBootstrap indicates the agent brings its own bootstrap credential, e.g. one
+	// provisioned by external tooling, so the manager ships no bootstrap kubeconfig secret
+	// for this config. Without it an empty AgentBootstrapKubeConfig is reported as a
+	// registration misconfiguration.
+	ExternalBootstrap bool
+
+	// SANs are the DNS subject alternative names requested on the CSRs of the agent, e.g.
+	// the service names of a webhook registering for a serving certificate. Leave it empty
+	// for a plain client-auth certificate.
+	SANs []string
+
+	// ExpirationSeconds is the requested lifetime of the client certificate of the agent,
+	// set as spec.expirationSeconds on its CSRs. If it is zero the default of the signer is
+	// used. The signer may issue a shorter certificate. Values below the 600 seconds
+	// minimum of the CSR API are ignored.
+	ExpirationSeconds int32
+}
+
+// WithDefaults fills in the unset fields of the registration config from the agent options,
+// resolving the install namespace for the given cluster so the bootstrap secret always lands
+// in the namespace the deploy path installs the agent into
+func (c RegistrationConfig) WithDefaults(options AgentAddonOptions, cluster *clusterv1.ManagedCluster) RegistrationConfig {
+	if len(c.AddonName) == 0 {
+		c.AddonName = options.AddonName
+	}
+	if len(c.InstallNamespace) == 0 {
+		c.InstallNamespace = options.InstallNamespace(cluster)
+	}
+	if len(c.BootstrapSecret) == 0 {
+		c.BootstrapSecret = fmt.Sprintf("%s-bootstrap-kubeconfig", c.AddonName)
+	}
+	if len(c.HubKubeconfigSecret) == 0 {
+		c.HubKubeconfigSecret = fmt.Sprintf("%s-hub-kubeconfig", c.AddonName)
+	}
+	if c.ExpirationSeconds != 0 && c.ExpirationSeconds < 600 {
+		// fall back to the default lifetime of the signer instead of requesting a value
+		// the CSR API would reject
+		c.ExpirationSeconds = 0
+	}
+	return c
+}
+
+// AgentAddonOptions are the options of an agent addon.
+type AgentAddonOptions struct {
+	// AddonName is the name of the addon. It should be consistent with the name of the
+	// corresponding ClusterManagementAddOn.
+	AddonName string
+
+	// AddonInstallNamespace is the namespace on the managed cluster in which the addon
+	// agent is installed.
+	AddonInstallNamespace string
+
+	// AddonInstallNamespaceFn resolves the install namespace per managed cluster, taking
+	// precedence over AddonInstallNamespace when it returns a non-empty value. The deploy
+	// and registration paths resolve through the same function, so the registration secrets
+	// always land in the namespace the agent is deployed to.
+	AddonInstallNamespaceFn func(cluster *clusterv1.ManagedCluster) string
+
+	// AddonConfigGVR is the GroupVersionResource of the addon configuration resource
+	// referenced by the addOnConfiguration coordinates of the ManagedClusterAddOn. If it
+	// is empty, no configuration is fetched before rendering the agent manifests.
+	AddonConfigGVR schema.GroupVersionResource
+}
+
+// InstallNamespace returns the install namespace of the agent on the given managed cluster,
+// from AddonInstallNamespaceFn if one is set and resolves to a non-empty value, and from
+// AddonInstallNamespace otherwise
+func (o AgentAddonOptions) InstallNamespace(cluster *clusterv1.ManagedCluster) string {
+	if o.AddonInstallNamespaceFn != nil && cluster != nil {
+		if namespace := o.AddonInstallNamespaceFn(cluster); len(namespace) != 0 {
+			return namespace
+		}
+	}
+	return o.AddonInstallNamespace
+}
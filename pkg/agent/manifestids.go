@@ -0,0 +1,31 @@
+package agent
+
+import (
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// ManifestWithID is an agent manifest tagged with a stable author-declared identity. The ID
+// survives renames and kind changes of the object it tags, so the deploy controller can tell
+// a renamed resource from a removed one without inferring from the object coordinates.
+type ManifestWithID struct {
+	// ID is the stable identity of the manifest. It must be non-empty and unique within
+	// one render.
+	ID string
+
+	// Object is the manifest resource to be deployed on the managed cluster.
+	Object runtime.Object
+}
+
+// AgentAddonWithManifestIDs is an AgentAddon declaring a stable identity per manifest. The
+// deploy controller records the identities on the deploy manifestwork and computes on every
+// re-render which identities disappeared; their resources drop out of the work and are
+// garbage-collected on the managed cluster, while an identity whose object was merely renamed
+// is pruned under its old coordinates without touching the new ones. The deploy controller
+// calls AgentManifestsWithIDs instead of AgentManifests when this interface is implemented.
+type AgentAddonWithManifestIDs interface {
+	AgentAddon
+
+	// AgentManifestsWithIDs is AgentManifests with a stable identity per manifest.
+	AgentManifestsWithIDs(cluster *clusterv1.ManagedCluster, config runtime.Object) ([]ManifestWithID, error)
+}
@@ -0,0 +1,27 @@
+package agent
+
+import (
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// MonitoringClaimName is the cluster claim a managed cluster reports when a prometheus
+// monitoring operator, and with it the ServiceMonitor and PodMonitor CRDs, is present. The
+// deploy controller only ships the monitoring manifests of an addon to clusters reporting
+// this claim, so a missing monitoring operator degrades to an unmonitored agent instead of a
+// failing deploy work.
+const MonitoringClaimName = "monitoring.open-cluster-management.io"
+
+// AgentAddonWithMonitoring is an AgentAddon whose agent exports metrics and ships scrape
+// configuration making them discoverable, e.g. ServiceMonitor or PodMonitor manifests. This
+// is about the metrics of the addon agent itself, not the metrics of the framework. The
+// manifests are deployed together with the agent manifests, but only on clusters reporting
+// the MonitoringClaimName claim.
+type AgentAddonWithMonitoring interface {
+	AgentAddon
+
+	// AgentMonitoringManifests returns the scrape configuration manifests for the agent of
+	// the addon on the given cluster. The config is the same addon configuration resource
+	// AgentManifests receives.
+	AgentMonitoringManifests(cluster *clusterv1.ManagedCluster, config runtime.Object) ([]runtime.Object, error)
+}
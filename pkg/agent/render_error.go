@@ -0,0 +1,49 @@
+package agent
+
+import (
+	"fmt"
+)
+
+// RenderError is a structured manifest rendering error. Rendering implementations built on
+// templates, e.g. the Helm and template adapters, should return it from AgentManifests so the
+// failing file, manifest and field show up readably in the addon status instead of an opaque
+// error string. A plain error from AgentManifests keeps working; this type is additive.
+type RenderError struct {
+	// File is the template file that failed to render, if known
+	File string
+	// Index is the index of the failing manifest in the rendered list, or -1 if unknown
+	Index int
+	// Field is the path of the failing field within the manifest, if known
+	Field string
+	// Err is the underlying error
+	Err error
+}
+
+func (e *RenderError) Error() string {
+	message := "failed to render manifest"
+	if len(e.File) > 0 {
+		message = fmt.Sprintf("%s from file %q", message, e.File)
+	}
+	if e.Index >= 0 {
+		message = fmt.Sprintf("%s at index %d", message, e.Index)
+	}
+	if len(e.Field) > 0 {
+		message = fmt.Sprintf("%s on field %q", message, e.Field)
+	}
+	return fmt.Sprintf("%s: %v", message, e.Err)
+}
+
+func (e *RenderError) Unwrap() error {
+	return e.Err
+}
+
+// NewRenderError wraps the given error with the rendering coordinates known by the caller. Pass
+// an empty file or field and a negative index when a coordinate is unknown.
+func NewRenderError(file string, index int, field string, err error) *RenderError {
+	return &RenderError{
+		File:  file,
+		Index: index,
+		Field: field,
+		Err:   err,
+	}
+}
@@ -0,0 +1,22 @@
+package agent
+
+// InstallScope declares how the agent of an addon installs on the managed cluster, so the
+// framework can manage namespaces and RBAC accordingly instead of inferring the intent from
+// how AgentManifests is written.
+type InstallScope string
+
+const (
+	// InstallScopeDefault keeps the historical behavior of inferring everything from the
+	// manifests the author ships.
+	InstallScopeDefault InstallScope = ""
+
+	// InstallScopeSingleNamespace declares a namespaced agent: the framework manages the
+	// install namespace and namespaced RBAC is the right fit, see
+	// helpers.AgentNamespaceRBAC.
+	InstallScopeSingleNamespace InstallScope = "SingleNamespace"
+
+	// InstallScopeCluster declares an agent shipping cluster-wide resources: the install
+	// namespace only hosts the agent workload and ClusterRole-based RBAC is the right fit,
+	// see helpers.AgentClusterRBAC.
+	InstallScopeCluster InstallScope = "Cluster"
+)
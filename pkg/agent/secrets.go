@@ -0,0 +1,22 @@
+package agent
+
+import (
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// AgentAddonWithHubSecrets is an AgentAddon whose manifest rendering additionally receives
+// the secrets matching the selector configured with WithHubSecretInputs from the cluster
+// namespace on the hub, e.g. a cloud credential or a registry token to template into the
+// spoke manifests. A change to a matching secret requeues the deploy. The deploy controller
+// calls AgentManifestsWithHubSecrets instead of AgentManifests when this interface is
+// implemented. Implementations must not log the secret contents.
+type AgentAddonWithHubSecrets interface {
+	AgentAddon
+
+	// AgentManifestsWithHubSecrets is AgentManifests with the matching secrets of the
+	// cluster namespace, sorted by name. The list is empty when no secret matches or no
+	// selector is configured on the manager.
+	AgentManifestsWithHubSecrets(cluster *clusterv1.ManagedCluster, config runtime.Object, secrets []*corev1.Secret) ([]runtime.Object, error)
+}
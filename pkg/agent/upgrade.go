@@ -0,0 +1,18 @@
+package agent
+
+// UpgradeStrategy declares how the deploy controller rolls changed agent manifests out to the
+// managed clusters.
+type UpgradeStrategy string
+
+const (
+	// UpgradeStrategyRollingUpdate updates the deploy work in place so the work agent patches
+	// the changed resources, which for a Deployment means a rolling update. This is the
+	// default.
+	UpgradeStrategyRollingUpdate UpgradeStrategy = "RollingUpdate"
+
+	// UpgradeStrategyRecreate deletes changed manifests the author flagged for recreation
+	// before their new version is applied, for stateful agents that cannot survive an
+	// in-place update across a breaking change. Unflagged manifests keep rolling update
+	// semantics.
+	UpgradeStrategyRecreate UpgradeStrategy = "Recreate"
+)
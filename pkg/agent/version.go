@@ -0,0 +1,100 @@
+package agent
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+)
+
+// AgentAddonWithSupportedVersions is an AgentAddon that only works on a range of spoke
+// Kubernetes versions, e.g. because its manifests use APIs removed in newer releases. The
+// deploy controller checks the range against the Kubernetes version reported in the
+// ManagedCluster status before deploying, and skips clusters outside of it until they upgrade
+// into range.
+type AgentAddonWithSupportedVersions interface {
+	AgentAddon
+
+	// SupportedKubernetesVersions returns the range of spoke Kubernetes versions the agent
+	// supports.
+	SupportedKubernetesVersions() KubernetesVersionRange
+}
+
+// KubernetesVersionRange is a range of Kubernetes versions. An empty bound is unbounded, so
+// the zero value includes every version.
+type KubernetesVersionRange struct {
+	// Min is the lowest supported version, inclusive, e.g. "1.19.0"
+	Min string
+	// Max is the lowest unsupported version, exclusive, e.g. "1.23.0"
+	Max string
+}
+
+// Includes returns whether the given version falls into the range. The version may carry a
+// leading "v" and a pre-release or build suffix, as reported by the managed cluster.
+func (r KubernetesVersionRange) Includes(version string) (bool, error) {
+	parsed, err := parseKubernetesVersion(version)
+	if err != nil {
+		return false, err
+	}
+
+	if len(r.Min) > 0 {
+		min, err := parseKubernetesVersion(r.Min)
+		if err != nil {
+			return false, err
+		}
+		if compareVersions(parsed, min) < 0 {
+			return false, nil
+		}
+	}
+
+	if len(r.Max) > 0 {
+		max, err := parseKubernetesVersion(r.Max)
+		if err != nil {
+			return false, err
+		}
+		if compareVersions(parsed, max) >= 0 {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// ClusterKubernetesVersion returns the Kubernetes version the managed cluster reports in its
+// status, or an empty string if the cluster has not reported one yet
+func ClusterKubernetesVersion(cluster *clusterv1.ManagedCluster) string {
+	return cluster.Status.Version.Kubernetes
+}
+
+// parseKubernetesVersion parses a "v<major>.<minor>.<patch>" version, tolerating a missing
+// patch or minor and dropping any pre-release or build suffix
+func parseKubernetesVersion(version string) ([3]int, error) {
+	parsed := [3]int{}
+	trimmed := strings.TrimPrefix(version, "v")
+	if index := strings.IndexAny(trimmed, "-+"); index >= 0 {
+		trimmed = trimmed[:index]
+	}
+
+	parts := strings.Split(trimmed, ".")
+	if len(parts) == 0 || len(parts) > 3 {
+		return parsed, fmt.Errorf("invalid kubernetes version %q", version)
+	}
+	for index, part := range parts {
+		value, err := strconv.Atoi(part)
+		if err != nil {
+			return parsed, fmt.Errorf("invalid kubernetes version %q: %v", version, err)
+		}
+		parsed[index] = value
+	}
+	return parsed, nil
+}
+
+func compareVersions(a, b [3]int) int {
+	for index := range a {
+		if a[index] != b[index] {
+			return a[index] - b[index]
+		}
+	}
+	return 0
+}
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager"
+	"github.com/open-cluster-management/addon-framework/pkg/agent"
+	"github.com/open-cluster-management/addon-framework/pkg/version"
+	"github.com/openshift/library-go/pkg/controller/controllercmd"
+	"github.com/spf13/cobra"
+)
+
+// NewManager returns the cobra command starting the hub manager of the given agent addon. An
+// addon binary embeds it next to the agent command.
+func NewManager(agentAddon agent.AgentAddon) *cobra.Command {
+	managerOptions := addonmanager.NewManagerOptions(agentAddon)
+	cmd := controllercmd.
+		NewControllerCommandConfig("manager", version.Get(), managerOptions.RunControllerManager).
+		NewCommand()
+	cmd.Use = "manager"
+	cmd.Short = "Start the Addon Manager"
+	managerOptions.AddFlags(cmd.Flags())
+	return cmd
+}
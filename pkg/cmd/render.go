@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// renderOptions holds the inputs of the render command: the synthetic managed cluster the
+// manifests are rendered for and an optional addon configuration.
+type renderOptions struct {
+	clusterName string
+	clusterFile string
+	configFile  string
+}
+
+// NewRender returns the cobra command rendering the agent manifests of the given addon
+// manager for a synthetic managed cluster and printing them as multi-document YAML, in the
+// spirit of "helm template". It runs the full deploy manifest pipeline of the manager without
+// a hub, so the output matches what the deploy controller would package into the deploy
+// manifestwork, for development loops and CI assertions on the rendered output.
+func NewRender(manager *addonmanager.AddonManager) *cobra.Command {
+	o := &renderOptions{}
+	cmd := &cobra.Command{
+		Use:   "render",
+		Short: "Render the agent manifests for a synthetic managed cluster",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return o.run(manager, cmd.OutOrStdout())
+		},
+	}
+	cmd.Flags().StringVar(&o.clusterName, "cluster-name", "cluster1",
+		"Name of the synthetic managed cluster the manifests are rendered for.")
+	cmd.Flags().StringVar(&o.clusterFile, "cluster-file", o.clusterFile,
+		"Path of a YAML or JSON file with a full ManagedCluster to render for, e.g. to exercise cluster claims. Overrides --cluster-name.")
+	cmd.Flags().StringVar(&o.configFile, "config-file", o.configFile,
+		"Path of a YAML or JSON file with the addon configuration passed to the rendering. Empty renders without a configuration.")
+	return cmd
+}
+
+func (o *renderOptions) run(manager *addonmanager.AddonManager, out io.Writer) error {
+	cluster, err := o.loadCluster()
+	if err != nil {
+		return err
+	}
+	config, err := o.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	manifests, err := manager.RenderManifests(cluster, config)
+	if err != nil {
+		return err
+	}
+	return writeManifests(out, manifests)
+}
+
+// loadCluster builds the managed cluster the manifests are rendered for, either a minimal one
+// named by --cluster-name or the full object read from --cluster-file
+func (o *renderOptions) loadCluster() (*clusterv1.ManagedCluster, error) {
+	if len(o.clusterFile) == 0 {
+		return &clusterv1.ManagedCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: o.clusterName},
+		}, nil
+	}
+
+	raw, err := ioutil.ReadFile(o.clusterFile)
+	if err != nil {
+		return nil, err
+	}
+	cluster := &clusterv1.ManagedCluster{}
+	if err := yaml.Unmarshal(raw, cluster); err != nil {
+		return nil, fmt.Errorf("failed to decode the managed cluster from %q: %v", o.clusterFile, err)
+	}
+	return cluster, nil
+}
+
+// loadConfig reads the addon configuration from --config-file as an unstructured object, or
+// returns nil when none is given
+func (o *renderOptions) loadConfig() (runtime.Object, error) {
+	if len(o.configFile) == 0 {
+		return nil, nil
+	}
+
+	raw, err := ioutil.ReadFile(o.configFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &unstructured.Unstructured{}
+	if err := yaml.Unmarshal(raw, config); err != nil {
+		return nil, fmt.Errorf("failed to decode the addon configuration from %q: %v", o.configFile, err)
+	}
+	return config, nil
+}
+
+// writeManifests prints the rendered work manifests as multi-document YAML
+func writeManifests(out io.Writer, manifests []workapiv1.Manifest) error {
+	for _, manifest := range manifests {
+		rendered, err := yaml.JSONToYAML(manifest.Raw)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "---\n%s", rendered); err != nil {
+			return err
+		}
+	}
+	return nil
+}
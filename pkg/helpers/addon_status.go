@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"context"
+
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	addonv1alpha1client "github.com/open-cluster-management/api/client/addon/clientset/versioned"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+)
+
+// UpdateAddonStatusFunc mutates the status of a ManagedClusterAddOn in place
+type UpdateAddonStatusFunc func(status *addonapiv1alpha1.ManagedClusterAddOnStatus) error
+
+// UpdateAddonStatus applies the given update functions on the status of the addon and updates
+// the status subresource if anything changed. Conditions added or changed by the update
+// functions are stamped with the generation of the fetched addon as their ObservedGeneration,
+// so consumers can tell whether a condition reflects the latest spec. It returns the updated
+// status and whether an update happened, and retries on conflicts.
+func UpdateAddonStatus(
+	ctx context.Context,
+	client addonv1alpha1client.Interface,
+	namespace, name string,
+	updateFuncs ...UpdateAddonStatusFunc) (*addonapiv1alpha1.ManagedClusterAddOnStatus, bool, error) {
+	updated := false
+	var updatedAddonStatus *addonapiv1alpha1.ManagedClusterAddOnStatus
+
+	err := retry.RetryOnConflict(retry.DefaultBackoff, func() error {
+		addon, err := client.AddonV1alpha1().ManagedClusterAddOns(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		oldStatus := &addon.Status
+
+		newStatus := oldStatus.DeepCopy()
+		for _, update := range updateFuncs {
+			if err := update(newStatus); err != nil {
+				return err
+			}
+		}
+		stampObservedGeneration(oldStatus.Conditions, newStatus.Conditions, addon.Generation)
+		if equality.Semantic.DeepEqual(oldStatus, newStatus) {
+			// no work to be done
+			updatedAddonStatus = newStatus
+			return nil
+		}
+
+		addon.Status = *newStatus
+		updatedAddon, err := client.AddonV1alpha1().ManagedClusterAddOns(namespace).UpdateStatus(ctx, addon, metav1.UpdateOptions{})
+		if err != nil {
+			return err
+		}
+		updatedAddonStatus = &updatedAddon.Status
+		updated = true
+		return nil
+	})
+	if errors.IsNotFound(err) {
+		// the addon is deleted, nothing to update
+		return nil, false, nil
+	}
+
+	return updatedAddonStatus, updated, err
+}
+
+// stampObservedGeneration sets the given generation as the ObservedGeneration of every
+// condition added or changed by the update functions
+func stampObservedGeneration(oldConditions, newConditions []metav1.Condition, generation int64) {
+	for i := range newConditions {
+		oldCondition := meta.FindStatusCondition(oldConditions, newConditions[i].Type)
+		if oldCondition == nil || !equality.Semantic.DeepEqual(*oldCondition, newConditions[i]) {
+			newConditions[i].ObservedGeneration = generation
+		}
+	}
+}
+
+// UpdateAddonConditionFn returns an update function setting the given condition on the addon status
+func UpdateAddonConditionFn(cond metav1.Condition) UpdateAddonStatusFunc {
+	return func(status *addonapiv1alpha1.ManagedClusterAddOnStatus) error {
+		meta.SetStatusCondition(&status.Conditions, cond)
+		return nil
+	}
+}
@@ -0,0 +1,82 @@
+package helpers
+
+import (
+	"context"
+	"testing"
+
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	fakeaddon "github.com/open-cluster-management/api/client/addon/clientset/versioned/fake"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestUpdateAddonStatusStampsObservedGeneration(t *testing.T) {
+	addon := &addonapiv1alpha1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test",
+			Namespace:  "cluster1",
+			Generation: 3,
+		},
+	}
+	addonClient := fakeaddon.NewSimpleClientset(addon)
+
+	_, updated, err := UpdateAddonStatus(context.TODO(), addonClient, "cluster1", "test",
+		UpdateAddonConditionFn(metav1.Condition{
+			Type:    "Available",
+			Status:  metav1.ConditionTrue,
+			Reason:  "Test",
+			Message: "test",
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated {
+		t.Fatal("expected the addon status to be updated")
+	}
+
+	actual, err := addonClient.AddonV1alpha1().ManagedClusterAddOns("cluster1").Get(context.TODO(), "test", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond := meta.FindStatusCondition(actual.Status.Conditions, "Available")
+	if cond == nil {
+		t.Fatal("expected an Available condition")
+	}
+	if cond.ObservedGeneration != addon.Generation {
+		t.Errorf("expected observed generation %d, got %d", addon.Generation, cond.ObservedGeneration)
+	}
+}
+
+func TestUpdateAddonStatusKeepsObservedGenerationOfUnchangedCondition(t *testing.T) {
+	addon := &addonapiv1alpha1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       "test",
+			Namespace:  "cluster1",
+			Generation: 3,
+		},
+		Status: addonapiv1alpha1.ManagedClusterAddOnStatus{
+			Conditions: []metav1.Condition{{
+				Type:               "Available",
+				Status:             metav1.ConditionTrue,
+				Reason:             "Test",
+				Message:            "test",
+				ObservedGeneration: 3,
+			}},
+		},
+	}
+	addonClient := fakeaddon.NewSimpleClientset(addon)
+
+	_, updated, err := UpdateAddonStatus(context.TODO(), addonClient, "cluster1", "test",
+		UpdateAddonConditionFn(metav1.Condition{
+			Type:    "Available",
+			Status:  metav1.ConditionTrue,
+			Reason:  "Test",
+			Message: "test",
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated {
+		t.Error("expected no status update for an unchanged condition")
+	}
+}
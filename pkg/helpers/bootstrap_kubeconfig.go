@@ -0,0 +1,159 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	restclient "k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// HubAPIServerURL resolves the externally reachable URL of the hub API server for a bootstrap
+// kubeconfig. The precedence order is: the explicit override when non-empty, for hubs behind a
+// custom ingress; then the server published in the cluster-info config map of the kube-public
+// namespace, which the registration bootstrap also relies on; and finally the host of the
+// given rest config, which inside the hub is usually the in-cluster service URL that agents on
+// the managed clusters cannot reach. Prefer this over passing restConfig.Host directly when
+// assembling a bootstrap kubeconfig.
+func HubAPIServerURL(ctx context.Context, kubeClient kubernetes.Interface, restConfig *restclient.Config, override string) (string, error) {
+	if len(override) > 0 {
+		return override, nil
+	}
+
+	configMap, err := kubeClient.CoreV1().ConfigMaps(metav1.NamespacePublic).Get(ctx, "cluster-info", metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		// fall through to the rest config host
+	case err != nil:
+		return "", err
+	default:
+		if kubeconfigData, ok := configMap.Data["kubeconfig"]; ok {
+			clusterInfo, err := clientcmd.Load([]byte(kubeconfigData))
+			if err != nil {
+				return "", fmt.Errorf("failed to parse the kubeconfig of the cluster-info config map: %v", err)
+			}
+			for _, cluster := range clusterInfo.Clusters {
+				if len(cluster.Server) > 0 {
+					return cluster.Server, nil
+				}
+			}
+		}
+	}
+
+	return restConfig.Host, nil
+}
+
+// HubCABundle resolves the CA bundle agents use to validate the serving certificate of the
+// hub. The explicit bundle wins when non-empty, for hubs serving a certificate not signed by
+// the in-cluster CA; otherwise the bundle is discovered from the given rest config. Sourcing
+// both the bootstrap kubeconfig and the deployed CA config map from here keeps the bootstrap
+// and runtime trust stores from drifting apart.
+func HubCABundle(restConfig *restclient.Config, caBundle []byte) ([]byte, error) {
+	if len(caBundle) > 0 {
+		return caBundle, nil
+	}
+	if len(restConfig.CAData) > 0 {
+		return restConfig.CAData, nil
+	}
+	if len(restConfig.CAFile) > 0 {
+		return ioutil.ReadFile(restConfig.CAFile)
+	}
+	return nil, nil
+}
+
+// RewriteKubeConfigServer rewrites every cluster entry of the given kubeconfig to the given
+// server URL, replacing the CA bundle when one is given, e.g. to route a bootstrap through
+// the endpoint of a cluster-proxy deployment instead of the direct hub endpoint
+func RewriteKubeConfigServer(kubeconfig []byte, server string, caBundle []byte) ([]byte, error) {
+	config, err := clientcmd.Load(kubeconfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the kubeconfig to rewrite: %v", err)
+	}
+	for _, cluster := range config.Clusters {
+		cluster.Server = server
+		if len(caBundle) > 0 {
+			cluster.CertificateAuthorityData = caBundle
+			cluster.CertificateAuthority = ""
+		}
+	}
+	return clientcmd.Write(*config)
+}
+
+// BootstrapKubeConfigFromToken mints a ServiceAccount token with the TokenRequest API and
+// assembles a kubeconfig from it, suitable to return from AgentBootstrapKubeConfig. It
+// standardizes token-based bootstrap so addons do not re-implement TokenRequest handling. The
+// token expires after the given expiry; combine with WithBootstrapKubeconfigRotation using an
+// interval shorter than the expiry so a fresh token is re-shipped before the old one runs out.
+// The serverURL names the hub API server endpoint written into the kubeconfig; when empty it
+// is resolved with HubAPIServerURL, so agents on the managed clusters are not pointed at an
+// in-cluster host they cannot reach. The caBundle overrides the CA discovered from the rest
+// config when non-empty; pass the bundle configured with WithHubCABundle so the bootstrap
+// trusts the same CA the agent mounts at runtime.
+func BootstrapKubeConfigFromToken(
+	restConfig *restclient.Config,
+	serverURL string,
+	saName, saNamespace string,
+	audiences []string,
+	expiry time.Duration,
+	caBundle []byte) ([]byte, error) {
+	kubeClient, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := HubAPIServerURL(context.TODO(), kubeClient, restConfig, serverURL)
+	if err != nil {
+		return nil, err
+	}
+
+	expirationSeconds := int64(expiry.Seconds())
+	tokenRequest, err := kubeClient.CoreV1().ServiceAccounts(saNamespace).CreateToken(
+		context.TODO(),
+		saName,
+		&authenticationv1.TokenRequest{
+			Spec: authenticationv1.TokenRequestSpec{
+				Audiences:         audiences,
+				ExpirationSeconds: &expirationSeconds,
+			},
+		},
+		metav1.CreateOptions{},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request a token for serviceaccount %s/%s: %v", saNamespace, saName, err)
+	}
+
+	caData, err := HubCABundle(restConfig, caBundle)
+	if err != nil {
+		return nil, err
+	}
+
+	kubeconfig := clientcmdapi.Config{
+		Clusters: map[string]*clientcmdapi.Cluster{
+			"hub": {
+				Server:                   server,
+				CertificateAuthorityData: caData,
+			},
+		},
+		AuthInfos: map[string]*clientcmdapi.AuthInfo{
+			"bootstrap": {
+				Token: tokenRequest.Status.Token,
+			},
+		},
+		Contexts: map[string]*clientcmdapi.Context{
+			"bootstrap": {
+				Cluster:  "hub",
+				AuthInfo: "bootstrap",
+			},
+		},
+		CurrentContext: "bootstrap",
+	}
+
+	return clientcmd.Write(kubeconfig)
+}
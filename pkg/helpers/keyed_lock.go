@@ -0,0 +1,37 @@
+package helpers
+
+import "sync"
+
+// KeyedLock serializes operations sharing a key, e.g. the reconciles of several controllers
+// acting on the same cluster namespace. One mutex is kept per key seen; the set of keys is
+// expected to be small and long-lived, like the clusters of a hub, so entries are never
+// reclaimed.
+type KeyedLock struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// NewKeyedLock returns an empty KeyedLock
+func NewKeyedLock() *KeyedLock {
+	return &KeyedLock{locks: map[string]*sync.Mutex{}}
+}
+
+// Lock acquires the lock of the given key, blocking while another holder has it
+func (l *KeyedLock) Lock(key string) {
+	l.mu.Lock()
+	lock, ok := l.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		l.locks[key] = lock
+	}
+	l.mu.Unlock()
+	lock.Lock()
+}
+
+// Unlock releases the lock of the given key
+func (l *KeyedLock) Unlock(key string) {
+	l.mu.Lock()
+	lock := l.locks[key]
+	l.mu.Unlock()
+	lock.Unlock()
+}
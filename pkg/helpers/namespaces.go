@@ -0,0 +1,19 @@
+package helpers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// IsProtectedNamespace returns whether the given namespace is one of the system namespaces an
+// addon agent should not be installed into. Deploying into kube-system and friends ties the
+// lifecycle of the agent to namespaces that can never be cleaned up and puts its objects next
+// to the cluster control plane, so the namespace-resolution paths reject these namespaces
+// unless the operator explicitly allowlists them.
+func IsProtectedNamespace(namespace string) bool {
+	switch namespace {
+	case metav1.NamespaceSystem, metav1.NamespacePublic, corev1.NamespaceNodeLease:
+		return true
+	}
+	return false
+}
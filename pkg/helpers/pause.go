@@ -0,0 +1,12 @@
+package helpers
+
+import (
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+)
+
+// AddonPaused returns whether the reconciliation of the addon is paused with the paused
+// annotation. The hub controllers make no work mutations on a paused addon.
+func AddonPaused(addon *addonapiv1alpha1.ManagedClusterAddOn) bool {
+	return addon.Annotations[constants.AddonPausedAnnotation] == "true"
+}
@@ -0,0 +1,111 @@
+package helpers
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// AgentClusterRBAC returns the ServiceAccount, ClusterRole and ClusterRoleBinding granting
+// the given rules cluster-wide to the agent of an addon on the managed cluster, for agents
+// declared with the Cluster install scope that manage cluster-scoped resources. Append the
+// returned objects to the manifest list and reference the service account from the agent pod
+// spec.
+func AgentClusterRBAC(namespace, serviceAccount string, rules []rbacv1.PolicyRule) []runtime.Object {
+	return []runtime.Object{
+		&corev1.ServiceAccount{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "ServiceAccount",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      serviceAccount,
+				Namespace: namespace,
+			},
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "rbac.authorization.k8s.io/v1",
+				Kind:       "ClusterRole",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: serviceAccount,
+			},
+			Rules: rules,
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "rbac.authorization.k8s.io/v1",
+				Kind:       "ClusterRoleBinding",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: serviceAccount,
+			},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "ClusterRole",
+				Name:     serviceAccount,
+			},
+			Subjects: []rbacv1.Subject{
+				{
+					Kind:      "ServiceAccount",
+					Name:      serviceAccount,
+					Namespace: namespace,
+				},
+			},
+		},
+	}
+}
+
+// AgentNamespaceRBAC returns the ServiceAccount, Role and RoleBinding granting the given rules
+// to the agent of an addon within its install namespace on the managed cluster. It saves the
+// addon authors from rewriting the same boilerplate in AgentManifests; append the returned
+// objects to the manifest list and reference the service account from the agent pod spec.
+func AgentNamespaceRBAC(namespace, serviceAccount string, rules []rbacv1.PolicyRule) []runtime.Object {
+	return []runtime.Object{
+		&corev1.ServiceAccount{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "ServiceAccount",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      serviceAccount,
+				Namespace: namespace,
+			},
+		},
+		&rbacv1.Role{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "rbac.authorization.k8s.io/v1",
+				Kind:       "Role",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      serviceAccount,
+				Namespace: namespace,
+			},
+			Rules: rules,
+		},
+		&rbacv1.RoleBinding{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "rbac.authorization.k8s.io/v1",
+				Kind:       "RoleBinding",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      serviceAccount,
+				Namespace: namespace,
+			},
+			RoleRef: rbacv1.RoleRef{
+				APIGroup: "rbac.authorization.k8s.io",
+				Kind:     "Role",
+				Name:     serviceAccount,
+			},
+			Subjects: []rbacv1.Subject{
+				{
+					Kind:      "ServiceAccount",
+					Name:      serviceAccount,
+					Namespace: namespace,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,70 @@
+package helpers
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/openshift/library-go/pkg/controller/factory"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+// DefaultTransientRequeueDelay is the default delay a sync failing on a transient error is
+// requeued with, instead of the aggressive per-item rate limiter of the work queue
+const DefaultTransientRequeueDelay = 30 * time.Second
+
+// RequeueError asks for the sync to be retried after the given delay instead of the default
+// backoff of the work queue. Return it from a sync wrapped with RequeueOnTransient when the
+// appropriate retry interval is known, e.g. while waiting on an external system.
+type RequeueError struct {
+	Err          error
+	RequeueAfter time.Duration
+}
+
+func (e *RequeueError) Error() string {
+	return fmt.Sprintf("%v (requeue after %v)", e.Err, e.RequeueAfter)
+}
+
+func (e *RequeueError) Unwrap() error {
+	return e.Err
+}
+
+// NewRequeueError wraps the error with a suggested requeue delay
+func NewRequeueError(err error, requeueAfter time.Duration) *RequeueError {
+	return &RequeueError{Err: err, RequeueAfter: requeueAfter}
+}
+
+// IsTransientError returns whether the error is a known-transient API error that a later
+// retry may resolve, e.g. a conflict, a timeout or throttling by the apiserver
+func IsTransientError(err error) bool {
+	return apierrors.IsConflict(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServiceUnavailable(err)
+}
+
+// RequeueOnTransient translates the error of a sync into the requeue behavior of the work
+// queue: a RequeueError and a known-transient API error requeue the key after a fixed delay
+// and are swallowed, so a sustained outage does not hammer the apiserver with the tight
+// per-item backoff, while any other error keeps the default rate limited retry. Pass a zero
+// delay for the default.
+func RequeueOnTransient(syncCtx factory.SyncContext, err error, delay time.Duration) error {
+	if err == nil {
+		return nil
+	}
+	if delay <= 0 {
+		delay = DefaultTransientRequeueDelay
+	}
+
+	var requeueErr *RequeueError
+	if errors.As(err, &requeueErr) {
+		syncCtx.Queue().AddAfter(syncCtx.QueueKey(), requeueErr.RequeueAfter)
+		return nil
+	}
+	if IsTransientError(err) {
+		syncCtx.Queue().AddAfter(syncCtx.QueueKey(), delay)
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,76 @@
+package testing
+
+import (
+	"github.com/open-cluster-management/addon-framework/pkg/agent"
+	clusterv1 "github.com/open-cluster-management/api/cluster/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// FakeAgentAddon is a configurable AgentAddon for controller tests. It returns the fixed
+// manifests and error it was built with and counts how often AgentManifests is called, so
+// tests can exercise error paths and assert render invocations without defining a bespoke
+// addon type per test file.
+type FakeAgentAddon struct {
+	// Options are returned from GetAgentAddonOptions; set at least the addon name.
+	Options agent.AgentAddonOptions
+
+	// Manifests and ManifestsErr are returned from AgentManifests.
+	Manifests    []runtime.Object
+	ManifestsErr error
+
+	// ManifestsCalled counts the AgentManifests invocations.
+	ManifestsCalled int
+}
+
+// NewFakeAgentAddon returns a fake agent addon rendering the given manifests, or failing the
+// render with the given error
+func NewFakeAgentAddon(addonName string, manifests []runtime.Object, err error) *FakeAgentAddon {
+	return &FakeAgentAddon{
+		Options:      agent.AgentAddonOptions{AddonName: addonName},
+		Manifests:    manifests,
+		ManifestsErr: err,
+	}
+}
+
+func (f *FakeAgentAddon) AgentManifests(cluster *clusterv1.ManagedCluster, config runtime.Object) ([]runtime.Object, error) {
+	f.ManifestsCalled++
+	return f.Manifests, f.ManifestsErr
+}
+
+func (f *FakeAgentAddon) GetAgentAddonOptions() agent.AgentAddonOptions {
+	return f.Options
+}
+
+// FakeAgentAddonWithRegistration is a FakeAgentAddon that also implements
+// AgentAddonWithRegistration with settable registration config, RBAC and bootstrap kubeconfig
+// return values.
+type FakeAgentAddonWithRegistration struct {
+	FakeAgentAddon
+
+	// Registration is returned from RegistrationConfig.
+	Registration agent.RegistrationConfig
+
+	// BootstrapKubeConfig and BootstrapErr are returned from AgentBootstrapKubeConfig.
+	BootstrapKubeConfig []byte
+	BootstrapErr        error
+}
+
+// NewFakeAgentAddonWithRegistration returns a fake agent addon with registration enabled,
+// bootstrapping with the given kubeconfig bytes
+func NewFakeAgentAddonWithRegistration(addonName string, manifests []runtime.Object, bootstrapKubeConfig []byte) *FakeAgentAddonWithRegistration {
+	return &FakeAgentAddonWithRegistration{
+		FakeAgentAddon: FakeAgentAddon{
+			Options:   agent.AgentAddonOptions{AddonName: addonName},
+			Manifests: manifests,
+		},
+		BootstrapKubeConfig: bootstrapKubeConfig,
+	}
+}
+
+func (f *FakeAgentAddonWithRegistration) RegistrationConfig(cluster *clusterv1.ManagedCluster) agent.RegistrationConfig {
+	return f.Registration
+}
+
+func (f *FakeAgentAddonWithRegistration) AgentBootstrapKubeConfig(cluster *clusterv1.ManagedCluster) ([]byte, error) {
+	return f.BootstrapKubeConfig, f.BootstrapErr
+}
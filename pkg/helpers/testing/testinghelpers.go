@@ -0,0 +1,72 @@
+package testing
+
+import (
+	"testing"
+
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// FakeSyncContext drives a controller sync directly with the given queue key, standing in for
+// the sync context the controller factory builds from its informer queue.
+type FakeSyncContext struct {
+	key      string
+	queue    workqueue.RateLimitingInterface
+	recorder events.Recorder
+}
+
+// NewFakeSyncContext returns a sync context reporting the given queue key
+func NewFakeSyncContext(t *testing.T, key string) *FakeSyncContext {
+	return &FakeSyncContext{
+		key:      key,
+		queue:    workqueue.NewRateLimitingQueue(workqueue.DefaultControllerRateLimiter()),
+		recorder: events.NewInMemoryRecorder(t.Name()),
+	}
+}
+
+func (f *FakeSyncContext) Queue() workqueue.RateLimitingInterface { return f.queue }
+
+func (f *FakeSyncContext) QueueKey() string { return f.key }
+
+func (f *FakeSyncContext) Recorder() events.Recorder { return f.recorder }
+
+// AssertAddonCondition asserts that the expected condition is present in the actual conditions.
+// The timestamps are ignored in the comparison.
+func AssertAddonCondition(t *testing.T, actual []metav1.Condition, expected metav1.Condition) {
+	t.Helper()
+	cond := meta.FindStatusCondition(actual, expected.Type)
+	if cond == nil {
+		t.Errorf("expected condition %q but got none", expected.Type)
+		return
+	}
+	if cond.Status != expected.Status {
+		t.Errorf("expected condition %q status %q but got %q", expected.Type, expected.Status, cond.Status)
+	}
+	if cond.Reason != expected.Reason {
+		t.Errorf("expected condition %q reason %q but got %q", expected.Type, expected.Reason, cond.Reason)
+	}
+	if len(expected.Message) > 0 && cond.Message != expected.Message {
+		t.Errorf("expected condition %q message %q but got %q", expected.Type, expected.Message, cond.Message)
+	}
+}
+
+// AssertAddonConditions asserts that all the expected conditions are present in the actual
+// conditions, e.g. a controller setting Available and Progressing together. The timestamps are
+// ignored in the comparison.
+func AssertAddonConditions(t *testing.T, actual []metav1.Condition, expected ...metav1.Condition) {
+	t.Helper()
+	for _, condition := range expected {
+		AssertAddonCondition(t, actual, condition)
+	}
+}
+
+// AssertNoAddonCondition asserts that no condition of the given type is present in the actual
+// conditions, e.g. after a controller removed a stale condition.
+func AssertNoAddonCondition(t *testing.T, actual []metav1.Condition, conditionType string) {
+	t.Helper()
+	if cond := meta.FindStatusCondition(actual, conditionType); cond != nil {
+		t.Errorf("expected no condition %q but got one with status %q", conditionType, cond.Status)
+	}
+}
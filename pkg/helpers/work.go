@@ -0,0 +1,153 @@
+package helpers
+
+import (
+	"context"
+	"fmt"
+
+	workv1client "github.com/open-cluster-management/api/client/work/clientset/versioned"
+	worklisterv1 "github.com/open-cluster-management/api/client/work/listers/work/v1"
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"github.com/openshift/library-go/pkg/operator/events"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/client-go/util/flowcontrol"
+)
+
+// ApplyWork creates the required manifestwork if it does not exist, and updates the existing
+// manifestwork if its spec, labels or owner references drift from the required one. Owner
+// references are reconciled so a work created by an older framework version with a different
+// owner is re-adopted instead of being garbage collected unexpectedly. It returns the applied
+// manifestwork and whether a write happened. A non-nil write limiter is consulted before each
+// Create or Update, but not for no-op applies, so a mass reconcile cannot burst-write the
+// whole fleet to the work API at once.
+func ApplyWork(
+	ctx context.Context,
+	client workv1client.Interface,
+	lister worklisterv1.ManifestWorkLister,
+	recorder events.Recorder,
+	writeLimiter flowcontrol.RateLimiter,
+	required *workapiv1.ManifestWork) (*workapiv1.ManifestWork, bool, error) {
+	existing, err := lister.ManifestWorks(required.Namespace).Get(required.Name)
+	switch {
+	case errors.IsNotFound(err):
+		if writeLimiter != nil {
+			writeLimiter.Accept()
+		}
+		created, err := client.WorkV1().ManifestWorks(required.Namespace).Create(ctx, required, metav1.CreateOptions{})
+		if err != nil {
+			return nil, false, err
+		}
+		recorder.Eventf("ManifestWorkCreated", "Created manifestwork %s/%s", required.Namespace, required.Name)
+		return created, true, nil
+	case err != nil:
+		return nil, false, err
+	}
+
+	annotationsChanged := false
+	for key, value := range required.Annotations {
+		if existing.Annotations[key] != value {
+			annotationsChanged = true
+		}
+	}
+	if !annotationsChanged &&
+		equality.Semantic.DeepEqual(existing.Spec, required.Spec) &&
+		equality.Semantic.DeepEqual(existing.Labels, required.Labels) &&
+		equality.Semantic.DeepEqual(existing.OwnerReferences, required.OwnerReferences) {
+		return existing, false, nil
+	}
+
+	work := existing.DeepCopy()
+	work.Spec = required.Spec
+	work.Labels = required.Labels
+	work.OwnerReferences = required.OwnerReferences
+	// the annotations of the required work are merged rather than replaced so markers other
+	// actors set on the work survive the update
+	if work.Annotations == nil && len(required.Annotations) > 0 {
+		work.Annotations = map[string]string{}
+	}
+	for key, value := range required.Annotations {
+		work.Annotations[key] = value
+	}
+	if writeLimiter != nil {
+		writeLimiter.Accept()
+	}
+	updated, err := client.WorkV1().ManifestWorks(work.Namespace).Update(ctx, work, metav1.UpdateOptions{})
+	if err != nil {
+		return nil, false, err
+	}
+	recorder.Eventf("ManifestWorkUpdated", "Updated manifestwork %s/%s", work.Namespace, work.Name)
+	return updated, true, nil
+}
+
+// DeleteOptionsWithPropagation renders the delete options carrying the given propagation
+// policy, or the default options when the policy is empty, so the cleanup paths honor the
+// delete strategy configured on the manager from one place
+func DeleteOptionsWithPropagation(policy metav1.DeletionPropagation) metav1.DeleteOptions {
+	options := metav1.DeleteOptions{}
+	if len(policy) > 0 {
+		options.PropagationPolicy = &policy
+	}
+	return options
+}
+
+// DecodeManifests decodes the raw manifests of the work back into objects, typed via the given
+// scheme where possible with an unstructured fallback for kinds the scheme does not know. It is
+// the single decode path for status mirroring, equality checks and testing assertions, so call
+// sites do not decode inconsistently.
+func DecodeManifests(work *workapiv1.ManifestWork, scheme *runtime.Scheme) ([]runtime.Object, error) {
+	decoder := serializer.NewCodecFactory(scheme).UniversalDeserializer()
+
+	objects := []runtime.Object{}
+	for index, manifest := range work.Spec.Workload.Manifests {
+		object, _, err := decoder.Decode(manifest.Raw, nil, nil)
+		if err == nil {
+			objects = append(objects, object)
+			continue
+		}
+		if !runtime.IsNotRegisteredError(err) {
+			return nil, fmt.Errorf("failed to decode manifest at index %d of work %s/%s: %v", index, work.Namespace, work.Name, err)
+		}
+
+		unstructuredObject := &unstructured.Unstructured{}
+		if err := unstructuredObject.UnmarshalJSON(manifest.Raw); err != nil {
+			return nil, fmt.Errorf("failed to decode manifest at index %d of work %s/%s: %v", index, work.Namespace, work.Name, err)
+		}
+		objects = append(objects, unstructuredObject)
+	}
+	return objects, nil
+}
+
+// DecodeUnstructuredManifests decodes the raw manifests of the work into unstructured objects
+func DecodeUnstructuredManifests(work *workapiv1.ManifestWork) ([]*unstructured.Unstructured, error) {
+	objects := []*unstructured.Unstructured{}
+	for index, manifest := range work.Spec.Workload.Manifests {
+		unstructuredObject := &unstructured.Unstructured{}
+		if err := unstructuredObject.UnmarshalJSON(manifest.Raw); err != nil {
+			return nil, fmt.Errorf("failed to decode manifest at index %d of work %s/%s: %v", index, work.Namespace, work.Name, err)
+		}
+		objects = append(objects, unstructuredObject)
+	}
+	return objects, nil
+}
+
+// NewManifest encodes the given object into a work manifest
+func NewManifest(object runtime.Object) (workapiv1.Manifest, error) {
+	unstructuredObject, ok := object.(*unstructured.Unstructured)
+	if !ok {
+		content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(object)
+		if err != nil {
+			return workapiv1.Manifest{}, err
+		}
+		unstructuredObject = &unstructured.Unstructured{Object: content}
+	}
+
+	rawObject, err := unstructuredObject.MarshalJSON()
+	if err != nil {
+		return workapiv1.Manifest{}, err
+	}
+	return workapiv1.Manifest{RawExtension: runtime.RawExtension{Raw: rawObject}}, nil
+}
@@ -0,0 +1,69 @@
+package helpers
+
+import (
+	"fmt"
+
+	workapiv1 "github.com/open-cluster-management/api/work/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WorkloadAvailabilityFeedback returns a condition mapper for WithConditionMapper deriving
+// the given addon condition from the Available manifest condition the work agent reports for
+// the named resource of the deploy work. It codifies reading the per-manifest status feedback
+// for standard workloads so every addon does not hand-write the matching; the condition is
+// Unknown while the work agent has not reported on the resource yet.
+// TODO: assemble typed status feedback rules instead once the vendored work API carries them.
+func WorkloadAvailabilityFeedback(conditionType, group, kind, namespace, name string) func(work *workapiv1.ManifestWork) []metav1.Condition {
+	return func(work *workapiv1.ManifestWork) []metav1.Condition {
+		for _, manifest := range work.Status.ResourceStatus.Manifests {
+			resourceMeta := manifest.ResourceMeta
+			if resourceMeta.Group != group || resourceMeta.Kind != kind ||
+				resourceMeta.Namespace != namespace || resourceMeta.Name != name {
+				continue
+			}
+
+			available := meta.FindStatusCondition(manifest.Conditions, string(workapiv1.ManifestAvailable))
+			if available == nil {
+				break
+			}
+			reason := "WorkloadUnavailable"
+			if available.Status == metav1.ConditionTrue {
+				reason = "WorkloadAvailable"
+			}
+			return []metav1.Condition{{
+				Type:    conditionType,
+				Status:  available.Status,
+				Reason:  reason,
+				Message: fmt.Sprintf("The work agent reports %s %s/%s available=%s", kind, namespace, name, available.Status),
+			}}
+		}
+
+		return []metav1.Condition{{
+			Type:    conditionType,
+			Status:  metav1.ConditionUnknown,
+			Reason:  "NoStatusReported",
+			Message: fmt.Sprintf("The work agent has not reported on %s %s/%s yet", kind, namespace, name),
+		}}
+	}
+}
+
+// DeploymentAvailabilityFeedback returns a condition mapper reporting the availability of the
+// named agent deployment as the DeploymentAvailable condition on the addon. Addons tracking
+// several workloads of the same kind should use WorkloadAvailabilityFeedback with distinct
+// condition types instead, as the condition type here is fixed.
+func DeploymentAvailabilityFeedback(namespace, name string) func(work *workapiv1.ManifestWork) []metav1.Condition {
+	return WorkloadAvailabilityFeedback("DeploymentAvailable", "apps", "Deployment", namespace, name)
+}
+
+// DaemonSetAvailabilityFeedback returns a condition mapper reporting the availability of the
+// named agent daemonset as the DaemonSetAvailable condition on the addon
+func DaemonSetAvailabilityFeedback(namespace, name string) func(work *workapiv1.ManifestWork) []metav1.Condition {
+	return WorkloadAvailabilityFeedback("DaemonSetAvailable", "apps", "DaemonSet", namespace, name)
+}
+
+// StatefulSetAvailabilityFeedback returns a condition mapper reporting the availability of the
+// named agent statefulset as the StatefulSetAvailable condition on the addon
+func StatefulSetAvailabilityFeedback(namespace, name string) func(work *workapiv1.ManifestWork) []metav1.Condition {
+	return WorkloadAvailabilityFeedback("StatefulSetAvailable", "apps", "StatefulSet", namespace, name)
+}
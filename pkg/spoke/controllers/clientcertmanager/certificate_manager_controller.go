@@ -2,40 +2,175 @@ package clientcertmanager
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/controllers/addonmetrics"
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addonv1alpha1client "github.com/open-cluster-management/api/client/addon/clientset/versioned"
 	addoninformerv1alpha1 "github.com/open-cluster-management/api/client/addon/informers/externalversions/addon/v1alpha1"
 	addonlisterv1alpha1 "github.com/open-cluster-management/api/client/addon/listers/addon/v1alpha1"
 	"github.com/openshift/library-go/pkg/controller/factory"
 	"github.com/openshift/library-go/pkg/operator/events"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	corev1informers "k8s.io/client-go/informers/core/v1"
 	"k8s.io/client-go/kubernetes"
 	restclient "k8s.io/client-go/rest"
+	"k8s.io/klog/v2"
 )
 
+// registrationConfig is the registration config of an addon read from the registration config
+// map deployed by the hub manager in the addon install namespace
+type registrationConfig struct {
+	addonName           string
+	signerName          string
+	bootstrapSecret     string
+	hubKubeconfigSecret string
+	// expirationSeconds is the certificate lifetime to request on the CSRs; zero means the
+	// default of the signer. The rotation threshold scales with it so short-lived certs are
+	// renewed in time.
+	expirationSeconds int32
+}
+
+// readConfigFromConfigMap reads the registration config from the given config map. The secret
+// names are taken from the config map so that the hub manager stays the single source of the
+// names; the historical name patterns are only used as a fallback for config maps shipped by
+// older managers that did not carry them.
+func readConfigFromConfigMap(configMap *corev1.ConfigMap) *registrationConfig {
+	config := &registrationConfig{
+		addonName:           configMap.Data[constants.RegistrationConfigAddonNameKey],
+		signerName:          configMap.Data[constants.RegistrationConfigSignerNameKey],
+		bootstrapSecret:     configMap.Data[constants.RegistrationConfigBootstrapSecretKey],
+		hubKubeconfigSecret: configMap.Data[constants.RegistrationConfigHubKubeconfigSecretKey],
+	}
+
+	if len(config.bootstrapSecret) == 0 {
+		config.bootstrapSecret = fmt.Sprintf("%s-bootstrap-kubeconfig", config.addonName)
+	}
+	if len(config.hubKubeconfigSecret) == 0 {
+		config.hubKubeconfigSecret = fmt.Sprintf("%s-hub-kubeconfig", config.addonName)
+	}
+	if value, ok := configMap.Data[constants.RegistrationConfigExpirationSecondsKey]; ok {
+		if expirationSeconds, err := strconv.ParseInt(value, 10, 32); err == nil {
+			config.expirationSeconds = int32(expirationSeconds)
+		}
+	}
+
+	return config
+}
+
+// SecretKeys maps the logical entries of the registration secrets to the data keys they are
+// stored under. Environments where the secrets are provisioned by external tooling such as
+// vault or external-secrets may use a different key layout; the zero value of every field
+// falls back to the conventional key.
+type SecretKeys struct {
+	// Kubeconfig is the data key of the kubeconfig, defaulting to "kubeconfig"
+	Kubeconfig string
+	// TLSKey is the data key of the client certificate key, defaulting to "tls.key"
+	TLSKey string
+	// TLSCert is the data key of the client certificate, defaulting to "tls.crt"
+	TLSCert string
+}
+
+// DefaultSecretKeys returns the conventional secret key layout
+func DefaultSecretKeys() SecretKeys {
+	return SecretKeys{}.withDefaults()
+}
+
+func (k SecretKeys) withDefaults() SecretKeys {
+	if len(k.Kubeconfig) == 0 {
+		k.Kubeconfig = "kubeconfig"
+	}
+	if len(k.TLSKey) == 0 {
+		k.TLSKey = "tls.key"
+	}
+	if len(k.TLSCert) == 0 {
+		k.TLSCert = "tls.crt"
+	}
+	return k
+}
+
+// RenewalOptions control when the hub client certificate is renewed proactively, before it
+// actually expires. The zero value keeps the historical reactive behavior of only replacing a
+// client config once it has become invalid.
+type RenewalOptions struct {
+	// LeadFraction is the fraction of the certificate lifetime after which renewal kicks
+	// in, e.g. 0.8 renews once 80% of the lifetime has passed. Zero disables proactive
+	// renewal; values above 1 are clamped to 1.
+	LeadFraction float64
+
+	// CertDuration caps the certificate lifetime the renewal deadline is computed from, for
+	// signers that issue longer certificates than the agent wants to rely on. Zero uses the
+	// lifetime of the issued certificate as is.
+	CertDuration time.Duration
+}
+
+func (r RenewalOptions) withDefaults() RenewalOptions {
+	if r.LeadFraction > 1 {
+		r.LeadFraction = 1
+	}
+	return r
+}
+
+// csrPollInterval is the resync period of the certificate manager; it bounds how long a
+// pending renewal CSR waits between polls of its status on the hub
+const csrPollInterval = 5 * time.Minute
+
 type certificateManagerController struct {
-	clusterName     string
-	hubClientConfig *restclient.Config
-	kubeClient      kubernetes.Interface
-	hubAddonLister  addonlisterv1alpha1.ManagedClusterAddOnLister
-	secretInformer  corev1informers.SecretInformer
+	clusterName      string
+	installNamespace string
+	secretKeys       SecretKeys
+	renewal          RenewalOptions
+	writeTLSSecret   bool
+	pruneBootstrap   bool
+	hubClientConfig  *restclient.Config
+	kubeClient       kubernetes.Interface
+	hubKubeClient    kubernetes.Interface
+	hubAddonClient   addonv1alpha1client.Interface
+	hubAddonLister   addonlisterv1alpha1.ManagedClusterAddOnLister
+	secretInformer   corev1informers.SecretInformer
+	eventRecorder    events.Recorder
 }
 
 func NewCertificateManagetController(
 	clusterName string,
+	installNamespace string,
+	secretKeys SecretKeys,
+	renewal RenewalOptions,
+	writeTLSSecret bool,
+	pruneBootstrap bool,
 	kubeClient kubernetes.Interface,
 	hubClientConfig *restclient.Config,
+	hubKubeClient kubernetes.Interface,
+	hubAddonClient addonv1alpha1client.Interface,
 	hubAddonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
 	secretInformer corev1informers.SecretInformer,
 	recorder events.Recorder,
 ) factory.Controller {
 	c := &certificateManagerController{
-		clusterName:     clusterName,
-		kubeClient:      kubeClient,
-		hubClientConfig: hubClientConfig,
-		hubAddonLister:  hubAddonInformers.Lister(),
-		secretInformer:  secretInformer,
+		clusterName:      clusterName,
+		installNamespace: installNamespace,
+		secretKeys:       secretKeys.withDefaults(),
+		renewal:          renewal.withDefaults(),
+		writeTLSSecret:   writeTLSSecret,
+		pruneBootstrap:   pruneBootstrap,
+		kubeClient:       kubeClient,
+		hubClientConfig:  hubClientConfig,
+		hubKubeClient:    hubKubeClient,
+		hubAddonClient:   hubAddonClient,
+		hubAddonLister:   hubAddonInformers.Lister(),
+		secretInformer:   secretInformer,
+		eventRecorder:    recorder.WithComponentSuffix("certificate-manager-controller"),
 	}
 
 	return factory.New().
@@ -45,11 +180,351 @@ func NewCertificateManagetController(
 				return accessor.GetName()
 			},
 			hubAddonInformers.Informer()).
-		WithSync(c.sync).
+		WithSync(addonmetrics.InstrumentSync("CertificateManagerController", c.sync)).
+		ResyncEvery(csrPollInterval).
 		ToController("ClientCertManagerController", recorder)
 }
 
 func (c *certificateManagerController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
-	//TODO: implement the reconciliation logic
+	addonName := syncCtx.QueueKey()
+	if addonName == factory.DefaultQueueKey {
+		return nil
+	}
+	klog.V(4).Infof("Reconciling client certificate of addon %q", addonName)
+
+	// an addon registering with multiple signers ships one labeled config map per signer
+	configMapList, err := c.kubeClient.CoreV1().ConfigMaps(c.installNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(labels.Set{constants.AddonLabel: addonName}).String(),
+	})
+	if err != nil {
+		return err
+	}
+
+	configMaps := []corev1.ConfigMap{}
+	for _, configMap := range configMapList.Items {
+		if len(configMap.Data[constants.RegistrationConfigSignerNameKey]) > 0 {
+			configMaps = append(configMaps, configMap)
+		}
+	}
+
+	if len(configMaps) == 0 {
+		// fall back to the unlabeled config map name shipped by older managers
+		configMap, err := c.kubeClient.CoreV1().ConfigMaps(c.installNamespace).Get(
+			ctx, constants.RegistrationConfigMapName(addonName), metav1.GetOptions{})
+		switch {
+		case errors.IsNotFound(err):
+			// the registration of the addon is not enabled
+			return nil
+		case err != nil:
+			return err
+		}
+		configMaps = append(configMaps, *configMap)
+	}
+
+	for i := range configMaps {
+		config := readConfigFromConfigMap(&configMaps[i])
+		if err := c.ensureHubKubeconfigSecret(ctx, config); err != nil {
+			return err
+		}
+		if err := c.ensureTLSSecret(ctx, config); err != nil {
+			return err
+		}
+		if err := c.updateBootstrappedCondition(ctx, config); err != nil {
+			return err
+		}
+		if err := c.updateCertificateCondition(ctx, config); err != nil {
+			return err
+		}
+		if err := c.pruneBootstrapSecret(ctx, config); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruneBootstrapSecret deletes the bootstrap kubeconfig secret once a valid hub client config
+// exists, so the bootstrap credential does not linger on the managed cluster after it served
+// its purpose. The secret is never touched while the bootstrap is still pending, and a
+// re-bootstrap re-ships it through the registration work; combine with the bootstrap work
+// pruning of the hub manager, since otherwise the work agent recreates the secret right away.
+// Proactive certificate renewal runs over a CSR with the still-valid client certificate, so it
+// keeps working after the bootstrap secret is pruned.
+func (c *certificateManagerController) pruneBootstrapSecret(ctx context.Context, config *registrationConfig) error {
+	if !c.pruneBootstrap {
+		return nil
+	}
+
+	secret, err := c.kubeClient.CoreV1().Secrets(c.installNamespace).Get(ctx, config.hubKubeconfigSecret, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+	if !c.hasValidHubClientConfig(secret) {
+		// the bootstrap has not completed; the bootstrap secret is still needed
+		return nil
+	}
+
+	err = c.kubeClient.CoreV1().Secrets(c.installNamespace).Delete(ctx, config.bootstrapSecret, metav1.DeleteOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+	c.eventRecorder.Eventf("BootstrapSecretPruned", "Deleted bootstrap secret %s/%s after successful registration", c.installNamespace, config.bootstrapSecret)
 	return nil
 }
+
+// ensureTLSSecret mirrors the client certificate of the hub kubeconfig secret into a
+// kubernetes.io/tls typed secret named "<hub kubeconfig secret>-tls", for integrations that
+// only consume typed tls secrets. The mirror is maintained alongside the opaque secret and
+// only when enabled; the opaque secret stays the source of truth.
+func (c *certificateManagerController) ensureTLSSecret(ctx context.Context, config *registrationConfig) error {
+	if !c.writeTLSSecret {
+		return nil
+	}
+
+	source, err := c.kubeClient.CoreV1().Secrets(c.installNamespace).Get(ctx, config.hubKubeconfigSecret, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	certData, hasCert := source.Data[c.secretKeys.TLSCert]
+	keyData, hasKey := source.Data[c.secretKeys.TLSKey]
+	if !hasCert || !hasKey {
+		// no client certificate to mirror yet
+		return nil
+	}
+
+	tlsSecretName := fmt.Sprintf("%s-tls", config.hubKubeconfigSecret)
+	required := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      tlsSecretName,
+			Namespace: c.installNamespace,
+		},
+		Type: corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       certData,
+			corev1.TLSPrivateKeyKey: keyData,
+		},
+	}
+
+	existing, err := c.kubeClient.CoreV1().Secrets(c.installNamespace).Get(ctx, tlsSecretName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = c.kubeClient.CoreV1().Secrets(c.installNamespace).Create(ctx, required, metav1.CreateOptions{})
+		if err == nil {
+			c.eventRecorder.Eventf("TLSSecretCreated", "Created tls secret %s/%s from the hub kubeconfig secret", c.installNamespace, tlsSecretName)
+		}
+		return err
+	case err != nil:
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(existing.Data, required.Data) && existing.Type == required.Type {
+		return nil
+	}
+	updated := existing.DeepCopy()
+	updated.Data = required.Data
+	_, err = c.kubeClient.CoreV1().Secrets(c.installNamespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
+
+// updateBootstrappedCondition reports on the addon whether a valid hub client config exists, so
+// the hub manager can prune the bootstrap work once the credential is no longer needed
+func (c *certificateManagerController) updateBootstrappedCondition(ctx context.Context, config *registrationConfig) error {
+	secret, err := c.kubeClient.CoreV1().Secrets(c.installNamespace).Get(ctx, config.hubKubeconfigSecret, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+	if !c.hasValidHubClientConfig(secret) {
+		return nil
+	}
+
+	cond := metav1.Condition{
+		Type:    constants.RegistrationBootstrappedCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "HubClientConfigValid",
+		Message: fmt.Sprintf("The hub kubeconfig secret %s/%s holds a valid hub client config", c.installNamespace, config.hubKubeconfigSecret),
+	}
+	_, _, err = helpers.UpdateAddonStatus(ctx, c.hubAddonClient, c.clusterName, config.addonName, helpers.UpdateAddonConditionFn(cond))
+	return err
+}
+
+// updateCertificateCondition reports the client certificate of the agent on the addon, with
+// the agent identity and the certificate expiry in the message, so operators can see from the
+// hub which certificate the agent runs on and when it rotates instead of a stuck registration
+// looking identical to a healthy one
+func (c *certificateManagerController) updateCertificateCondition(ctx context.Context, config *registrationConfig) error {
+	secret, err := c.kubeClient.CoreV1().Secrets(c.installNamespace).Get(ctx, config.hubKubeconfigSecret, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		return nil
+	case err != nil:
+		return err
+	}
+
+	cert := c.clientCertificate(secret)
+	if cert == nil {
+		// no client certificate yet, e.g. a token-only kubeconfig
+		return nil
+	}
+
+	cond := metav1.Condition{
+		Type:   constants.ClusterCertificateRotatedCondition,
+		Status: metav1.ConditionTrue,
+		Reason: "ClientCertificateUpdated",
+		Message: fmt.Sprintf("The client certificate of agent %q (signer %q) is valid until %v",
+			cert.Subject.CommonName, config.signerName, cert.NotAfter.UTC().Format(time.RFC3339)),
+	}
+	_, _, err = helpers.UpdateAddonStatus(ctx, c.hubAddonClient, c.clusterName, config.addonName, helpers.UpdateAddonConditionFn(cond))
+	return err
+}
+
+// clientCertificate parses the client certificate held in the given secret, returning nil when
+// the secret holds none or it does not parse
+func (c *certificateManagerController) clientCertificate(secret *corev1.Secret) *x509.Certificate {
+	certData, ok := secret.Data[c.secretKeys.TLSCert]
+	if !ok {
+		return nil
+	}
+	block, _ := pem.Decode(certData)
+	if block == nil {
+		return nil
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil
+	}
+	return cert
+}
+
+// ensureHubKubeconfigSecret creates the hub kubeconfig secret from the bootstrap kubeconfig if
+// it does not exist yet, rotates its client certificate with a CSR on the hub once the
+// certificate passes the configured renewal deadline, and re-seeds the secret from the
+// bootstrap kubeconfig once its client config has become invalid, e.g. after the certificate
+// expired before a proactive renewal
+func (c *certificateManagerController) ensureHubKubeconfigSecret(ctx context.Context, config *registrationConfig) error {
+	existing, err := c.kubeClient.CoreV1().Secrets(c.installNamespace).Get(ctx, config.hubKubeconfigSecret, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		if c.hasValidHubClientConfig(existing) {
+			if c.dueForRenewal(existing) {
+				return c.renewViaCSR(ctx, config, existing)
+			}
+			return nil
+		}
+	case errors.IsNotFound(err):
+		existing = nil
+	default:
+		return err
+	}
+
+	bootstrapSecret, err := c.kubeClient.CoreV1().Secrets(c.installNamespace).Get(ctx, config.bootstrapSecret, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		// the bootstrap secret has not been deployed yet
+		return nil
+	case err != nil:
+		return err
+	}
+
+	if _, ok := bootstrapSecret.Data[c.secretKeys.Kubeconfig]; !ok {
+		return fmt.Errorf("bootstrap secret %s/%s has no %q key", c.installNamespace, config.bootstrapSecret, c.secretKeys.Kubeconfig)
+	}
+
+	if existing != nil {
+		// the reactive replacement: the client config of the existing secret is invalid
+		// and must be replaced to restore the hub connection; a pending renewal of the
+		// old certificate is moot at this point
+		updated := existing.DeepCopy()
+		updated.Data = bootstrapSecret.Data
+		delete(updated.Annotations, pendingCSRAnnotation)
+		if _, err := c.kubeClient.CoreV1().Secrets(c.installNamespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+		c.eventRecorder.Eventf("HubKubeconfigSecretReplaced", "Replaced the invalid hub client config in secret %s/%s from the bootstrap kubeconfig", c.installNamespace, config.hubKubeconfigSecret)
+		return nil
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      config.hubKubeconfigSecret,
+			Namespace: c.installNamespace,
+		},
+		Data: bootstrapSecret.Data,
+	}
+	_, err = c.kubeClient.CoreV1().Secrets(c.installNamespace).Create(ctx, secret, metav1.CreateOptions{})
+	if errors.IsAlreadyExists(err) {
+		return nil
+	}
+	if err == nil {
+		c.eventRecorder.Eventf("HubKubeconfigSecretCreated", "Created hub kubeconfig secret %s/%s from the bootstrap kubeconfig", c.installNamespace, config.hubKubeconfigSecret)
+	}
+	return err
+}
+
+// dueForRenewal returns whether the client certificate in the secret has passed the renewal
+// deadline derived from the configured lead fraction. A secret without a client certificate,
+// e.g. a token-only kubeconfig, is never due; an unparseable or expired certificate never
+// reaches this point since hasValidHubClientConfig rejects it.
+func (c *certificateManagerController) dueForRenewal(secret *corev1.Secret) bool {
+	if c.renewal.LeadFraction <= 0 {
+		return false
+	}
+
+	cert := c.clientCertificate(secret)
+	if cert == nil {
+		return false
+	}
+
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	if c.renewal.CertDuration > 0 && c.renewal.CertDuration < lifetime {
+		lifetime = c.renewal.CertDuration
+	}
+	deadline := cert.NotBefore.Add(time.Duration(float64(lifetime) * c.renewal.LeadFraction))
+	if time.Now().Before(deadline) {
+		return false
+	}
+
+	c.eventRecorder.Eventf("ClientCertificateDueForRenewal",
+		"Client certificate in secret %s/%s expires at %v and passed its renewal deadline %v",
+		secret.Namespace, secret.Name, cert.NotAfter, deadline)
+	return true
+}
+
+// hasValidHubClientConfig returns whether the hub kubeconfig secret holds a usable hub client
+// config under the configured key layout. The kubeconfig is always required; the client
+// certificate entries must come in pairs since a key without its certificate, or vice versa,
+// is unusable. A certificate that does not parse, is not yet valid or has expired counts as
+// invalid, so the bootstrap fallback replaces a client config that can no longer authenticate.
+func (c *certificateManagerController) hasValidHubClientConfig(secret *corev1.Secret) bool {
+	if _, ok := secret.Data[c.secretKeys.Kubeconfig]; !ok {
+		return false
+	}
+
+	_, hasKey := secret.Data[c.secretKeys.TLSKey]
+	_, hasCert := secret.Data[c.secretKeys.TLSCert]
+	if hasKey != hasCert {
+		return false
+	}
+	if !hasCert {
+		// a token-only kubeconfig carries no client certificate to validate
+		return true
+	}
+
+	cert := c.clientCertificate(secret)
+	if cert == nil {
+		return false
+	}
+	now := time.Now()
+	return now.After(cert.NotBefore) && now.Before(cert.NotAfter)
+}
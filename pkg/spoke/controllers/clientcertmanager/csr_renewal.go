@@ -0,0 +1,169 @@
+package clientcertmanager
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+
+	certificatesv1 "k8s.io/api/certificates/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// spokeClusterNameLabel is the label on a renewal CSR carrying the name of the managed
+	// cluster, matched by the CSR approve controller on the hub
+	spokeClusterNameLabel = "open-cluster-management.io/cluster-name"
+	// addonNameLabel is the label on a renewal CSR carrying the name of the addon
+	addonNameLabel = "open-cluster-management.io/addon-name"
+
+	// pendingCSRAnnotation is the annotation on the hub kubeconfig secret naming the renewal
+	// CSR currently pending on the hub, so the renewal survives agent restarts
+	pendingCSRAnnotation = "addon.open-cluster-management.io/pending-csr"
+
+	// stagingKeySuffix is appended to the TLS key data key to stage the private key of a
+	// pending renewal until the hub issues the matching certificate
+	stagingKeySuffix = ".next"
+
+	// renewalKeyBits is the size of the RSA key generated for a renewal CSR
+	renewalKeyBits = 2048
+)
+
+// renewViaCSR rotates the client certificate of the hub kubeconfig secret with a
+// CertificateSigningRequest on the hub, using the still-valid client certificate as the
+// credential, so renewal works without the bootstrap secret. The renewal spans several syncs: a
+// fresh private key is generated and staged on the secret alongside the name of the created
+// CSR; later syncs poll the CSR and, once the hub issues the certificate, swap the staged key
+// and the issued certificate into place.
+func (c *certificateManagerController) renewViaCSR(ctx context.Context, config *registrationConfig, secret *corev1.Secret) error {
+	csrName := secret.Annotations[pendingCSRAnnotation]
+	if len(csrName) == 0 {
+		return c.startCSRRenewal(ctx, config, secret)
+	}
+
+	csr, err := c.hubKubeClient.CertificatesV1().CertificateSigningRequests().Get(ctx, csrName, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		// the CSR was cleaned up on the hub; restart the renewal on the next sync
+		return c.clearPendingRenewal(ctx, secret)
+	case err != nil:
+		return err
+	}
+
+	for _, condition := range csr.Status.Conditions {
+		if condition.Type == certificatesv1.CertificateDenied || condition.Type == certificatesv1.CertificateFailed {
+			c.eventRecorder.Warningf("ClientCertificateRenewalRejected",
+				"Renewal CSR %q of addon %q was %s: %s", csrName, config.addonName, condition.Type, condition.Message)
+			return c.clearPendingRenewal(ctx, secret)
+		}
+	}
+
+	if len(csr.Status.Certificate) == 0 {
+		// the CSR is still pending approval or signing; polled again on the next resync
+		return nil
+	}
+
+	updated := secret.DeepCopy()
+	updated.Data[c.secretKeys.TLSCert] = csr.Status.Certificate
+	updated.Data[c.secretKeys.TLSKey] = updated.Data[c.secretKeys.TLSKey+stagingKeySuffix]
+	delete(updated.Data, c.secretKeys.TLSKey+stagingKeySuffix)
+	delete(updated.Annotations, pendingCSRAnnotation)
+	if _, err := c.kubeClient.CoreV1().Secrets(c.installNamespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+
+	// the issued certificate is persisted; the CSR on the hub is no longer needed
+	err = c.hubKubeClient.CertificatesV1().CertificateSigningRequests().Delete(ctx, csrName, metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	c.eventRecorder.Eventf("HubKubeconfigSecretRenewed",
+		"Renewed the client certificate of hub kubeconfig secret %s/%s with CSR %q before expiry", c.installNamespace, config.hubKubeconfigSecret, csrName)
+	return nil
+}
+
+// startCSRRenewal generates a fresh private key, creates the renewal CSR on the hub with the
+// subject of the current client certificate, and stages the key and the CSR name on the secret
+func (c *certificateManagerController) startCSRRenewal(ctx context.Context, config *registrationConfig, secret *corev1.Secret) error {
+	cert := c.clientCertificate(secret)
+	if cert == nil {
+		// without a client certificate there is no subject to renew under; the reactive
+		// bootstrap path handles such secrets
+		return nil
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, renewalKeyBits)
+	if err != nil {
+		return err
+	}
+	keyData := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	request, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:   cert.Subject.CommonName,
+			Organization: cert.Subject.Organization,
+		},
+	}, key)
+	if err != nil {
+		return err
+	}
+	requestData := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: request})
+
+	signerName := config.signerName
+	if len(signerName) == 0 {
+		signerName = certificatesv1.KubeAPIServerClientSignerName
+	}
+	csr := &certificatesv1.CertificateSigningRequest{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: fmt.Sprintf("addon-%s-%s-", c.clusterName, config.addonName),
+			Labels: map[string]string{
+				spokeClusterNameLabel: c.clusterName,
+				addonNameLabel:        config.addonName,
+			},
+		},
+		Spec: certificatesv1.CertificateSigningRequestSpec{
+			Request:    requestData,
+			SignerName: signerName,
+			Usages: []certificatesv1.KeyUsage{
+				certificatesv1.UsageDigitalSignature,
+				certificatesv1.UsageKeyEncipherment,
+				certificatesv1.UsageClientAuth,
+			},
+		},
+	}
+	created, err := c.hubKubeClient.CertificatesV1().CertificateSigningRequests().Create(ctx, csr, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+
+	updated := secret.DeepCopy()
+	updated.Data[c.secretKeys.TLSKey+stagingKeySuffix] = keyData
+	if updated.Annotations == nil {
+		updated.Annotations = map[string]string{}
+	}
+	updated.Annotations[pendingCSRAnnotation] = created.Name
+	if _, err := c.kubeClient.CoreV1().Secrets(c.installNamespace).Update(ctx, updated, metav1.UpdateOptions{}); err != nil {
+		// the secret no longer points at the CSR; remove it so it does not leak on the hub
+		_ = c.hubKubeClient.CertificatesV1().CertificateSigningRequests().Delete(ctx, created.Name, metav1.DeleteOptions{})
+		return err
+	}
+
+	c.eventRecorder.Eventf("ClientCertificateRenewalRequested",
+		"Created renewal CSR %q for the client certificate of addon %q", created.Name, config.addonName)
+	return nil
+}
+
+// clearPendingRenewal drops the staged key and the pending CSR annotation from the secret, so
+// a later sync starts a fresh renewal
+func (c *certificateManagerController) clearPendingRenewal(ctx context.Context, secret *corev1.Secret) error {
+	updated := secret.DeepCopy()
+	delete(updated.Data, c.secretKeys.TLSKey+stagingKeySuffix)
+	delete(updated.Annotations, pendingCSRAnnotation)
+	_, err := c.kubeClient.CoreV1().Secrets(c.installNamespace).Update(ctx, updated, metav1.UpdateOptions{})
+	return err
+}
@@ -2,8 +2,13 @@ package lease
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/controllers/addonmetrics"
+	"github.com/open-cluster-management/addon-framework/pkg/helpers"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
 	addonv1alpha1client "github.com/open-cluster-management/api/client/addon/clientset/versioned"
 	addoninformerv1alpha1 "github.com/open-cluster-management/api/client/addon/informers/externalversions/addon/v1alpha1"
 	addonlisterv1alpha1 "github.com/open-cluster-management/api/client/addon/listers/addon/v1alpha1"
@@ -11,6 +16,9 @@ import (
 	"github.com/openshift/library-go/pkg/controller/factory"
 	"github.com/openshift/library-go/pkg/operator/events"
 
+	coordv1 "k8s.io/api/coordination/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	coordinformers "k8s.io/client-go/informers/coordination/v1"
 	coordlisters "k8s.io/client-go/listers/coordination/v1"
 )
@@ -18,39 +26,156 @@ import (
 const (
 	addonLeaseDurationTimes   = 5
 	AddonLeaseDurationSeconds = 60
+
+	// DefaultLeaseStaleDuration is the default length of time a lease must stay stale for
+	// before the Available condition flips to false. Tolerating a short stale window damps
+	// flapping from clock skew or a renewal that lands right at the grace boundary,
+	// independent of how often the availability is recomputed.
+	DefaultLeaseStaleDuration = 2 * time.Minute
 )
 
 // leaseController checks the lease of managed clusters on hub cluster to determine whether a managed cluster is available.
 type addonLeaseController struct {
-	clusterName string
-	addonClient addonv1alpha1client.Interface
-	addonLister addonlisterv1alpha1.ManagedClusterAddOnLister
-	leaseLister coordlisters.LeaseLister
+	clusterName   string
+	addonClient   addonv1alpha1client.Interface
+	addonLister   addonlisterv1alpha1.ManagedClusterAddOnLister
+	leaseLister   coordlisters.LeaseLister
+	staleDuration time.Duration
+	// staleSince tracks per addon when its lease was first found stale. It is only touched
+	// from sync, which the factory controller never runs concurrently.
+	staleSince map[string]time.Time
 }
 
-// NewClusterLeaseController creates a cluster lease controller on hub cluster.
+// NewAddonLeaseController creates a controller evaluating the leases of the addon agents on the
+// spoke cluster and reporting the Available condition of the addons on hub. A lease must stay
+// stale for staleDuration before Available flips to false, while a single fresh evaluation
+// flips it back to true; pass 0 for the default duration. The resyncInterval governs how often
+// the availability is recomputed and thereby how quickly an unavailable agent is noticed;
+// every evaluation may update the addon status on the hub.
 func NewAddonLeaseController(
 	clusterName string,
 	addonClient addonv1alpha1client.Interface,
 	addonInformers addoninformerv1alpha1.ManagedClusterAddOnInformer,
 	leaseInformer coordinformers.LeaseInformer,
 	resyncInterval time.Duration,
+	staleDuration time.Duration,
 	recorder events.Recorder) factory.Controller {
+	if staleDuration <= 0 {
+		staleDuration = DefaultLeaseStaleDuration
+	}
 	c := &addonLeaseController{
-		clusterName: clusterName,
-		addonClient: addonClient,
-		addonLister: addonInformers.Lister(),
-		leaseLister: leaseInformer.Lister(),
+		clusterName:   clusterName,
+		addonClient:   addonClient,
+		addonLister:   addonInformers.Lister(),
+		leaseLister:   leaseInformer.Lister(),
+		staleDuration: staleDuration,
+		staleSince:    map[string]time.Time{},
 	}
 	return factory.New().
 		WithInformers(addonInformers.Informer(), leaseInformer.Informer()).
-		WithSync(c.sync).
+		WithSync(addonmetrics.InstrumentSync("AddonLeaseController", func(ctx context.Context, syncCtx factory.SyncContext) error {
+			// back off gently on transient API errors instead of the tight per-item retry
+			return helpers.RequeueOnTransient(syncCtx, c.sync(ctx, syncCtx), 0)
+		})).
 		ResyncEvery(resyncInterval).
 		ToController("ManagedClusterLeaseController", recorder)
 }
 
-// sync checks the lease of each accepted cluster on hub to determine whether a managed cluster is available.
+// sync checks the lease of each addon agent on the spoke cluster to determine whether the addon
+// is available, and reports the result on the ManagedClusterAddOn on hub.
 func (c *addonLeaseController) sync(ctx context.Context, syncCtx factory.SyncContext) error {
-	//TODO: implement the reconciliation logic
+	addons, err := c.addonLister.ManagedClusterAddOns(c.clusterName).List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	leases, err := c.leaseLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+
+	seen := map[string]bool{}
+	for _, addon := range addons {
+		seen[addon.Name] = true
+		if err := c.updateAddonAvailable(ctx, addon, findAddonLease(leases, addon)); err != nil {
+			return err
+		}
+	}
+
+	// drop the stale records of addons that no longer exist
+	for name := range c.staleSince {
+		if !seen[name] {
+			delete(c.staleSince, name)
+		}
+	}
+
 	return nil
 }
+
+// updateAddonAvailable reports the Available condition of the addon from its agent lease. A
+// stale lease only flips the condition to false once it has stayed stale for staleDuration,
+// while a fresh lease flips it back to true immediately.
+func (c *addonLeaseController) updateAddonAvailable(ctx context.Context, addon *addonapiv1alpha1.ManagedClusterAddOn, lease *coordv1.Lease) error {
+	if leaseFresh(lease) {
+		delete(c.staleSince, addon.Name)
+		cond := metav1.Condition{
+			Type:    "Available",
+			Status:  metav1.ConditionTrue,
+			Reason:  "ManagedClusterAddOnLeaseUpdated",
+			Message: "The addon agent is updating its lease",
+		}
+		_, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond))
+		return err
+	}
+
+	since, ok := c.staleSince[addon.Name]
+	if !ok {
+		c.staleSince[addon.Name] = time.Now()
+		return nil
+	}
+	if time.Since(since) < c.staleDuration {
+		// within the hysteresis window; keep the current condition to avoid flapping
+		return nil
+	}
+
+	cond := metav1.Condition{
+		Type:    "Available",
+		Status:  metav1.ConditionFalse,
+		Reason:  "ManagedClusterAddOnLeaseUpdateStopped",
+		Message: fmt.Sprintf("The addon agent stopped updating its lease for more than %v", c.staleDuration),
+	}
+	_, _, err := helpers.UpdateAddonStatus(ctx, c.addonClient, addon.Namespace, addon.Name, helpers.UpdateAddonConditionFn(cond))
+	return err
+}
+
+// findAddonLease returns the agent lease of the addon, or nil if the agent has not created
+// its lease yet. By default the lease is named after the addon and may live in any namespace;
+// an agent running outside of its install namespace can pin the lookup with the lease name
+// and lease namespace annotations on the ManagedClusterAddOn.
+func findAddonLease(leases []*coordv1.Lease, addon *addonapiv1alpha1.ManagedClusterAddOn) *coordv1.Lease {
+	leaseName := addon.Annotations[constants.AddonLeaseNameAnnotation]
+	if len(leaseName) == 0 {
+		leaseName = addon.Name
+	}
+	leaseNamespace := addon.Annotations[constants.AddonLeaseNamespaceAnnotation]
+
+	for _, lease := range leases {
+		if lease.Name != leaseName {
+			continue
+		}
+		if len(leaseNamespace) != 0 && lease.Namespace != leaseNamespace {
+			continue
+		}
+		return lease
+	}
+	return nil
+}
+
+// leaseFresh returns whether the lease has been renewed within the grace period
+func leaseFresh(lease *coordv1.Lease) bool {
+	if lease == nil || lease.Spec.RenewTime == nil {
+		return false
+	}
+	gracePeriod := time.Duration(addonLeaseDurationTimes*AddonLeaseDurationSeconds) * time.Second
+	return time.Since(lease.Spec.RenewTime.Time) <= gracePeriod
+}
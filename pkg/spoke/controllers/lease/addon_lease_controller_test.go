@@ -0,0 +1,178 @@
+package lease
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/open-cluster-management/addon-framework/pkg/addonmanager/constants"
+	addonapiv1alpha1 "github.com/open-cluster-management/api/addon/v1alpha1"
+	fakeaddon "github.com/open-cluster-management/api/client/addon/clientset/versioned/fake"
+	addoninformers "github.com/open-cluster-management/api/client/addon/informers/externalversions"
+	"github.com/openshift/library-go/pkg/controller/factory"
+	"github.com/openshift/library-go/pkg/operator/events"
+	coordv1 "k8s.io/api/coordination/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	fakekube "k8s.io/client-go/kubernetes/fake"
+
+	kubeinformers "k8s.io/client-go/informers"
+)
+
+const testClusterName = "cluster1"
+
+func newAddon(name string, annotations map[string]string) *addonapiv1alpha1.ManagedClusterAddOn {
+	return &addonapiv1alpha1.ManagedClusterAddOn{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   testClusterName,
+			Annotations: annotations,
+		},
+	}
+}
+
+func newLease(namespace, name string, renewTime time.Time) *coordv1.Lease {
+	return &coordv1.Lease{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: coordv1.LeaseSpec{
+			RenewTime: &metav1.MicroTime{Time: renewTime},
+		},
+	}
+}
+
+// newLeaseController builds the controller under test directly with fake clients, its listers
+// fed from the informer stores
+func newLeaseController(t *testing.T, staleDuration time.Duration, addon *addonapiv1alpha1.ManagedClusterAddOn, leases ...*coordv1.Lease) (*addonLeaseController, *fakeaddon.Clientset) {
+	addonClient := fakeaddon.NewSimpleClientset(addon)
+	addonInformerFactory := addoninformers.NewSharedInformerFactory(addonClient, 0)
+	addonInformer := addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns()
+	if err := addonInformer.Informer().GetStore().Add(addon); err != nil {
+		t.Fatal(err)
+	}
+
+	kubeInformerFactory := kubeinformers.NewSharedInformerFactory(fakekube.NewSimpleClientset(), 0)
+	leaseInformer := kubeInformerFactory.Coordination().V1().Leases()
+	for _, lease := range leases {
+		if err := leaseInformer.Informer().GetStore().Add(lease); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return &addonLeaseController{
+		clusterName:   testClusterName,
+		addonClient:   addonClient,
+		addonLister:   addonInformer.Lister(),
+		leaseLister:   leaseInformer.Lister(),
+		staleDuration: staleDuration,
+		staleSince:    map[string]time.Time{},
+	}, addonClient
+}
+
+func assertAvailableCondition(t *testing.T, addonClient *fakeaddon.Clientset, addonName string, status metav1.ConditionStatus) {
+	t.Helper()
+	addon, err := addonClient.AddonV1alpha1().ManagedClusterAddOns(testClusterName).Get(context.TODO(), addonName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cond := meta.FindStatusCondition(addon.Status.Conditions, "Available")
+	if cond == nil {
+		t.Fatalf("expected an Available condition on addon %q", addonName)
+	}
+	if cond.Status != status {
+		t.Errorf("expected Available condition %q, got %q (%s)", status, cond.Status, cond.Message)
+	}
+}
+
+func assertNoAvailableCondition(t *testing.T, addonClient *fakeaddon.Clientset, addonName string) {
+	t.Helper()
+	addon, err := addonClient.AddonV1alpha1().ManagedClusterAddOns(testClusterName).Get(context.TODO(), addonName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cond := meta.FindStatusCondition(addon.Status.Conditions, "Available"); cond != nil {
+		t.Errorf("expected no Available condition on addon %q, got %q", addonName, cond.Status)
+	}
+}
+
+func TestSyncFreshLease(t *testing.T) {
+	addon := newAddon("test", nil)
+	controller, addonClient := newLeaseController(t, DefaultLeaseStaleDuration, addon,
+		newLease("default", "test", time.Now()))
+
+	syncCtx := factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))
+	if err := controller.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	assertAvailableCondition(t, addonClient, "test", metav1.ConditionTrue)
+}
+
+func TestSyncStaleLeaseWithinHysteresis(t *testing.T) {
+	addon := newAddon("test", nil)
+	controller, addonClient := newLeaseController(t, DefaultLeaseStaleDuration, addon,
+		newLease("default", "test", time.Now().Add(-time.Hour)))
+
+	syncCtx := factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))
+	if err := controller.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the lease just turned stale; the condition must not flip within the stale duration
+	assertNoAvailableCondition(t, addonClient, "test")
+	if _, ok := controller.staleSince["test"]; !ok {
+		t.Error("expected the staleness of the lease to be recorded")
+	}
+}
+
+func TestSyncStaleLeaseBeyondHysteresis(t *testing.T) {
+	addon := newAddon("test", nil)
+	controller, addonClient := newLeaseController(t, DefaultLeaseStaleDuration, addon,
+		newLease("default", "test", time.Now().Add(-time.Hour)))
+	controller.staleSince["test"] = time.Now().Add(-2 * DefaultLeaseStaleDuration)
+
+	syncCtx := factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))
+	if err := controller.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	assertAvailableCondition(t, addonClient, "test", metav1.ConditionFalse)
+}
+
+func TestSyncRecoveredLease(t *testing.T) {
+	addon := newAddon("test", nil)
+	controller, addonClient := newLeaseController(t, DefaultLeaseStaleDuration, addon,
+		newLease("default", "test", time.Now()))
+	controller.staleSince["test"] = time.Now().Add(-2 * DefaultLeaseStaleDuration)
+
+	syncCtx := factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))
+	if err := controller.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	assertAvailableCondition(t, addonClient, "test", metav1.ConditionTrue)
+	if _, ok := controller.staleSince["test"]; ok {
+		t.Error("expected the stale record of the recovered lease to be dropped")
+	}
+}
+
+func TestSyncAnnotatedLeaseName(t *testing.T) {
+	addon := newAddon("test", map[string]string{
+		constants.AddonLeaseNameAnnotation:      "custom-lease",
+		constants.AddonLeaseNamespaceAnnotation: "custom-ns",
+	})
+	controller, addonClient := newLeaseController(t, DefaultLeaseStaleDuration, addon,
+		// a fresh lease under the default name must not count for the annotated addon
+		newLease("default", "test", time.Now()),
+		newLease("custom-ns", "custom-lease", time.Now().Add(-time.Hour)))
+	controller.staleSince["test"] = time.Now().Add(-2 * DefaultLeaseStaleDuration)
+
+	syncCtx := factory.NewSyncContext("test", events.NewInMemoryRecorder("test"))
+	if err := controller.sync(context.TODO(), syncCtx); err != nil {
+		t.Fatal(err)
+	}
+
+	assertAvailableCondition(t, addonClient, "test", metav1.ConditionFalse)
+}
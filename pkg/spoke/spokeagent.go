@@ -3,6 +3,7 @@ package spoke
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"time"
 
@@ -12,6 +13,8 @@ import (
 	addoninformers "github.com/open-cluster-management/api/client/addon/informers/externalversions"
 	"github.com/openshift/library-go/pkg/controller/controllercmd"
 	"github.com/spf13/pflag"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
@@ -23,19 +26,35 @@ const (
 	spokeAgentNameLength = 5
 	// defaultSpokeComponentNamespace is the default namespace in which the spoke agent is deployed
 	defaultSpokeComponentNamespace = "open-cluster-management"
+	// defaultStatusSyncInterval is the default cadence of the addon availability recomputation
+	defaultStatusSyncInterval = 1 * time.Minute
+	// defaultHubInformerResync is the default resync period of the hub-side informers of the
+	// agent
+	defaultHubInformerResync = 10 * time.Minute
 )
 
 // SpokeAgentOptions holds configuration for spoke cluster agent
 type SpokeAgentOptions struct {
-	ComponentNamespace string
-	ClusterName        string
-	HubKubeconfig      string
+	ComponentNamespace      string
+	ClusterName             string
+	HubKubeconfig           string
+	LeaseStaleDuration      time.Duration
+	CertRenewalLeadFraction float64
+	CertDuration            time.Duration
+	WriteTLSSecret          bool
+	PruneBootstrapSecret    bool
+	StatusSyncInterval      time.Duration
+	HubInformerResync       time.Duration
+	HubInformerSelector     string
 }
 
 // NewSpokeAgentOptions returns a SpokeAgentOptions
 func NewSpokeAgentOptions() *SpokeAgentOptions {
 	return &SpokeAgentOptions{
-		HubKubeconfig: "/spoke/hub-kubeconfig",
+		HubKubeconfig:      "/spoke/hub-kubeconfig",
+		LeaseStaleDuration: lease.DefaultLeaseStaleDuration,
+		StatusSyncInterval: defaultStatusSyncInterval,
+		HubInformerResync:  defaultHubInformerResync,
 	}
 }
 
@@ -65,23 +84,36 @@ func (o *SpokeAgentOptions) RunSpokeAgent(ctx context.Context, controllerContext
 	if err != nil {
 		return err
 	}
-	hubNamespacedKubeInformerFactory := informers.NewSharedInformerFactoryWithOptions(
-		hubKubeClient, 10*time.Minute,
-		informers.WithNamespace(o.ClusterName),
-	)
 
 	addonClient, err := addonclient.NewForConfig(hubClientConfig)
 	if err != nil {
 		return err
 	}
 	addonInformerFactory := addoninformers.NewSharedInformerFactoryWithOptions(
-		addonClient, 10*time.Minute, addoninformers.WithNamespace(o.ClusterName))
+		addonClient, o.HubInformerResync,
+		addoninformers.WithNamespace(o.ClusterName),
+		addoninformers.WithTweakListOptions(func(options *metav1.ListOptions) {
+			if len(o.HubInformerSelector) > 0 {
+				options.LabelSelector = o.HubInformerSelector
+			}
+		}),
+	)
 
 	// create another ClientCertForHubController for client certificate rotation
 	clientCertForHubController := clientcertmanager.NewCertificateManagetController(
 		o.ClusterName,
+		o.ComponentNamespace,
+		clientcertmanager.DefaultSecretKeys(),
+		clientcertmanager.RenewalOptions{
+			LeadFraction: o.CertRenewalLeadFraction,
+			CertDuration: o.CertDuration,
+		},
+		o.WriteTLSSecret,
+		o.PruneBootstrapSecret,
 		spokeKubeClient,
 		hubClientConfig,
+		hubKubeClient,
+		addonClient,
 		addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns(),
 		spokeKubeInformerFactory.Core().V1().Secrets(),
 		controllerContext.EventRecorder,
@@ -92,11 +124,11 @@ func (o *SpokeAgentOptions) RunSpokeAgent(ctx context.Context, controllerContext
 		addonClient,
 		addonInformerFactory.Addon().V1alpha1().ManagedClusterAddOns(),
 		spokeKubeInformerFactory.Coordination().V1().Leases(),
-		1*time.Minute,
+		o.StatusSyncInterval,
+		o.LeaseStaleDuration,
 		controllerContext.EventRecorder,
 	)
 
-	go hubNamespacedKubeInformerFactory.Start(ctx.Done())
 	go spokeKubeInformerFactory.Start(ctx.Done())
 	go addonInformerFactory.Start(ctx.Done())
 
@@ -113,6 +145,22 @@ func (o *SpokeAgentOptions) AddFlags(fs *pflag.FlagSet) {
 		"Cluster name of the addon installed")
 	fs.StringVar(&o.HubKubeconfig, "hub-kubeconfig", o.HubKubeconfig,
 		"The mount path of hub-kubeconfig in the container.")
+	fs.DurationVar(&o.LeaseStaleDuration, "lease-stale-duration", o.LeaseStaleDuration,
+		"How long the agent lease must stay stale before the addon is reported unavailable.")
+	fs.Float64Var(&o.CertRenewalLeadFraction, "cert-renewal-lead-fraction", o.CertRenewalLeadFraction,
+		"Fraction of the client certificate lifetime after which it is renewed proactively, e.g. 0.8. Zero keeps the reactive default.")
+	fs.DurationVar(&o.CertDuration, "cert-duration", o.CertDuration,
+		"Cap on the certificate lifetime the renewal deadline is computed from. Zero uses the issued lifetime.")
+	fs.BoolVar(&o.WriteTLSSecret, "write-tls-secret", o.WriteTLSSecret,
+		"Mirror the client certificate into a kubernetes.io/tls typed secret next to the hub kubeconfig secret.")
+	fs.BoolVar(&o.PruneBootstrapSecret, "prune-bootstrap-secret", o.PruneBootstrapSecret,
+		"Delete the bootstrap kubeconfig secret once a valid hub client config exists. Combine with the bootstrap work pruning of the hub manager.")
+	fs.DurationVar(&o.StatusSyncInterval, "status-sync-interval", o.StatusSyncInterval,
+		"Cadence of the addon availability recomputation from the agent leases. A faster cadence updates the Available condition sooner at the cost of more hub API calls per addon.")
+	fs.DurationVar(&o.HubInformerResync, "hub-informer-resync", o.HubInformerResync,
+		"Resync period of the hub-side informers of the agent. A longer period saves resources on constrained clusters at the cost of slower recovery from missed events.")
+	fs.StringVar(&o.HubInformerSelector, "hub-informer-label-selector", o.HubInformerSelector,
+		"Additional label selector restricting which ManagedClusterAddOns the hub-side informers of the agent watch, to shrink the watch footprint. Empty watches everything in the cluster namespace.")
 }
 
 // Validate verifies the inputs.
@@ -120,6 +168,12 @@ func (o *SpokeAgentOptions) Validate() error {
 	if o.ClusterName == "" {
 		return errors.New("cluster name is empty")
 	}
+	if o.HubInformerResync <= 0 {
+		return errors.New("hub informer resync period must be positive")
+	}
+	if _, err := labels.Parse(o.HubInformerSelector); err != nil {
+		return fmt.Errorf("invalid hub informer label selector %q: %v", o.HubInformerSelector, err)
+	}
 
 	return nil
 }